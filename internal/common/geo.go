@@ -0,0 +1,18 @@
+package common
+
+import (
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+	"math"
+)
+
+// earthRadiusKm is the mean radius of the earth
+const earthRadiusKm = 6371
+
+// Distance returns the great-circle distance between the coordinates in km
+func Distance(from types.Coordinates, to types.Coordinates) float64 {
+	dLat := (to.Latitude - from.Latitude) * math.Pi / 180
+	dLon := (to.Longitude - from.Longitude) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(from.Latitude*math.Pi/180)*math.Cos(to.Latitude*math.Pi/180)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,40 @@
+package geocode
+
+import (
+	"context"
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+)
+
+// Geocoder converts between addresses and coordinates, such as PositionStack or Nominatim
+type Geocoder interface {
+	// ReverseGeoCode returns the location at the coordinates, or nil if no location was found
+	ReverseGeoCode(ctx context.Context, coords types.Coordinates, lang string) (*types.Location, error)
+	// Search returns the places matching the query, best match first, with a confidence between 0 and 1
+	Search(ctx context.Context, query string, opts SearchOptions) ([]types.Place, error)
+}
+
+// LocalityFinder is implemented by geocoders that know the population of places, such as GeoNames
+type LocalityFinder interface {
+	// NearestLocality returns the nearest place with at least the population, or nil if there is none
+	NearestLocality(ctx context.Context, coords types.Coordinates, minPopulation int64, lang string) (*types.Place, error)
+}
+
+// TimeZoner is implemented by geocoders that know the time zone of places, such as GeoNames
+type TimeZoner interface {
+	// TimeZone returns the IANA time zone at the coordinates, or an empty string if it isn't known
+	TimeZone(coords types.Coordinates) string
+}
+
+// SearchOptions narrow down a forward geocoding search
+type SearchOptions struct {
+	Limit int
+	// Country is an ISO 3166 alpha-2 country code to bias results towards
+	Country string
+	// Region is a state, province or other region name to bias results towards
+	Region string
+	Lang   string
+	// Near is a location to bias results towards, e.g. the user's position when autocompleting
+	Near *types.Coordinates
+	// Autocomplete treats the query as the start of a place name that is still being typed
+	Autocomplete bool
+}
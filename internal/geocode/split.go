@@ -0,0 +1,43 @@
+package geocode
+
+import (
+	"context"
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+)
+
+// Split is a Geocoder using separate geocoders for forward and reverse lookups,
+// e.g. an online geocoder for addresses and an offline gazetteer for reverse lookups
+type Split struct {
+	Forward Geocoder
+	Reverse Geocoder
+}
+
+func (s Split) Search(ctx context.Context, query string, opts SearchOptions) ([]types.Place, error) {
+	return s.Forward.Search(ctx, query, opts)
+}
+
+// NearestLocality uses whichever geocoder is a LocalityFinder, preferring the reverse geocoder,
+// and returns nil if neither is
+func (s Split) NearestLocality(ctx context.Context, coords types.Coordinates, minPopulation int64, lang string) (*types.Place, error) {
+	for _, gc := range []Geocoder{s.Reverse, s.Forward} {
+		if finder, ok := gc.(LocalityFinder); ok {
+			return finder.NearestLocality(ctx, coords, minPopulation, lang)
+		}
+	}
+	return nil, nil
+}
+
+// TimeZone uses whichever geocoder is a TimeZoner, preferring the reverse geocoder,
+// and returns an empty string if neither is
+func (s Split) TimeZone(coords types.Coordinates) string {
+	for _, gc := range []Geocoder{s.Reverse, s.Forward} {
+		if tz, ok := gc.(TimeZoner); ok {
+			return tz.TimeZone(coords)
+		}
+	}
+	return ""
+}
+
+func (s Split) ReverseGeoCode(ctx context.Context, coords types.Coordinates, lang string) (*types.Location, error) {
+	return s.Reverse.ReverseGeoCode(ctx, coords, lang)
+}
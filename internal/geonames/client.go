@@ -0,0 +1,320 @@
+package geonames
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/geocode"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Place is a populated place from a GeoNames cities file
+// https://download.geonames.org/export/dump/readme.txt
+type Place struct {
+	Name        string
+	Latitude    float64
+	Longitude   float64
+	CountryCode string
+	Admin1Code  string
+	Population  int64
+	Timezone    string
+}
+
+type ClientOption func(*Client)
+
+// Client is an offline geocoder serving lookups from a GeoNames cities file loaded into memory
+type Client struct {
+	file       string
+	admin1File string
+
+	places []Place
+	// names maps lowercase place names and alternate names to the places with that name
+	names map[string][]int
+	// sortedNames are the keys of names in order, for finding names by prefix
+	sortedNames []string
+	// admin1 maps '<country code>.<admin1 code>' to the name of the region
+	admin1 map[string]string
+	grid   *grid
+}
+
+// FileOption sets the path of the cities file, e.g. cities15000.txt
+func FileOption(file string) ClientOption {
+	return func(c *Client) {
+		c.file = file
+	}
+}
+
+// Admin1FileOption sets the optional path of the admin1CodesASCII.txt file used to name regions
+func Admin1FileOption(file string) ClientOption {
+	return func(c *Client) {
+		c.admin1File = file
+	}
+}
+
+func New(opts ...ClientOption) *Client {
+	c := &Client{
+		names:  make(map[string][]int),
+		admin1: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.file == "" {
+		panic("Missing file in geonames client")
+	}
+	if err := c.loadPlaces(); err != nil {
+		panic(fmt.Sprintf("Error loading geonames file %v: %v", c.file, err.Error()))
+	}
+	if c.admin1File != "" {
+		if err := c.loadAdmin1(); err != nil {
+			panic(fmt.Sprintf("Error loading geonames admin1 file %v: %v", c.admin1File, err.Error()))
+		}
+	}
+	c.grid = newGrid(c.places)
+	for name := range c.names {
+		c.sortedNames = append(c.sortedNames, name)
+	}
+	sort.Strings(c.sortedNames)
+	return c
+}
+
+// ReverseGeoCode returns the nearest populated place to the coordinates
+func (c *Client) ReverseGeoCode(ctx context.Context, coords t.Coordinates, lang string) (*t.Location, error) {
+	place := c.Nearest(coords, 0)
+	if place == nil {
+		return nil, nil
+	}
+	return c.location(place), nil
+}
+
+// NearestLocality returns the nearest place to the coordinates with at least the population
+func (c *Client) NearestLocality(ctx context.Context, coords t.Coordinates, minPopulation int64, lang string) (*t.Place, error) {
+	place := c.Nearest(coords, minPopulation)
+	if place == nil {
+		return nil, nil
+	}
+	return &t.Place{
+		Label:       c.Label(place),
+		Coordinates: t.Coordinates{Latitude: place.Latitude, Longitude: place.Longitude},
+		Confidence:  1,
+	}, nil
+}
+
+// TimeZone returns the time zone of the nearest place to the coordinates
+func (c *Client) TimeZone(coords t.Coordinates) string {
+	place := c.Nearest(coords, 0)
+	if place == nil {
+		return ""
+	}
+	return place.Timezone
+}
+
+// Search returns the places matching the query, with the confidence being each place's share of the matches' population
+func (c *Client) Search(ctx context.Context, query string, opts geocode.SearchOptions) ([]t.Place, error) {
+	for _, hint := range []string{opts.Region, opts.Country} {
+		if hint != "" {
+			query = fmt.Sprintf("%v, %v", query, hint)
+		}
+	}
+	var matches []Place
+	if opts.Autocomplete {
+		matches = c.PrefixMatches(query)
+	} else {
+		matches = c.Matches(query)
+	}
+	if opts.Limit > 0 && len(matches) > opts.Limit {
+		matches = matches[:opts.Limit]
+	}
+
+	var total int64
+	for _, match := range matches {
+		total += match.Population
+	}
+	var places []t.Place
+	for i, match := range matches {
+		place := t.Place{
+			Label: c.Label(&matches[i]),
+			Coordinates: t.Coordinates{
+				Latitude:  match.Latitude,
+				Longitude: match.Longitude,
+			},
+			Confidence: 1 / float64(len(matches)),
+		}
+		if total > 0 {
+			place.Confidence = float64(match.Population) / float64(total)
+		}
+		places = append(places, place)
+	}
+	return places, nil
+}
+
+// Label returns a readable name for the place including its region and country, e.g. 'Springfield, Illinois, US'
+func (c *Client) Label(place *Place) string {
+	var parts []string
+	for _, part := range []string{place.Name, c.Region(place), place.CountryCode} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Matches returns the places matching the query, most populous first
+func (c *Client) Matches(query string) []Place {
+	name, hints := parseQuery(query)
+	return c.sortedMatches(c.names[name], hints)
+}
+
+// PrefixMatches returns the places with a name starting with the query, most populous first
+func (c *Client) PrefixMatches(query string) []Place {
+	prefix, hints := parseQuery(query)
+	if prefix == "" {
+		return nil
+	}
+	var indexes []int
+	seen := make(map[int]bool)
+	for i := sort.SearchStrings(c.sortedNames, prefix); i < len(c.sortedNames) && strings.HasPrefix(c.sortedNames[i], prefix); i++ {
+		for _, j := range c.names[c.sortedNames[i]] {
+			if !seen[j] {
+				seen[j] = true
+				indexes = append(indexes, j)
+			}
+		}
+	}
+	return c.sortedMatches(indexes, hints)
+}
+
+// parseQuery splits a query into the place name and the region or country hints after it
+func parseQuery(query string) (string, []string) {
+	parts := strings.Split(query, ",")
+	var hints []string
+	for _, hint := range parts[1:] {
+		if hint = normalize(hint); hint != "" {
+			hints = append(hints, hint)
+		}
+	}
+	return normalize(parts[0]), hints
+}
+
+func (c *Client) sortedMatches(indexes []int, hints []string) []Place {
+	var matches []Place
+	for _, i := range indexes {
+		if c.matchesHints(&c.places[i], hints) {
+			matches = append(matches, c.places[i])
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Population > matches[j].Population
+	})
+	return matches
+}
+
+// Nearest returns the nearest place to the coordinates with at least the given population, or nil if there are none
+func (c *Client) Nearest(coords t.Coordinates, minPopulation int64) *Place {
+	i := c.grid.nearest(c.places, coords, minPopulation)
+	if i < 0 {
+		return nil
+	}
+	return &c.places[i]
+}
+
+// Region returns the name of the place's region, falling back to its admin1 code
+func (c *Client) Region(place *Place) string {
+	if region, ok := c.admin1[place.CountryCode+"."+place.Admin1Code]; ok {
+		return region
+	}
+	return place.Admin1Code
+}
+
+func (c *Client) location(place *Place) *t.Location {
+	return &t.Location{
+		Locality: place.Name,
+		Region:   c.Region(place),
+		Country:  place.CountryCode,
+	}
+}
+
+// matchesHints returns whether every hint matches the place's country code, region code or region name
+func (c *Client) matchesHints(place *Place, hints []string) bool {
+	for _, hint := range hints {
+		if hint != strings.ToLower(place.CountryCode) && hint != strings.ToLower(place.Admin1Code) &&
+			hint != normalize(c.Region(place)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Client) loadPlaces() error {
+	f, err := os.Open(c.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// alternate names can make lines much longer than the default buffer
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 18 {
+			continue
+		}
+		lat, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			continue
+		}
+		lon, err := strconv.ParseFloat(fields[5], 64)
+		if err != nil {
+			continue
+		}
+		population, _ := strconv.ParseInt(fields[14], 10, 64)
+		c.places = append(c.places, Place{
+			Name:        fields[1],
+			Latitude:    lat,
+			Longitude:   lon,
+			CountryCode: fields[8],
+			Admin1Code:  fields[10],
+			Population:  population,
+			Timezone:    fields[17],
+		})
+
+		i := len(c.places) - 1
+		seen := make(map[string]bool)
+		names := append([]string{fields[1], fields[2]}, strings.Split(fields[3], ",")...)
+		for _, name := range names {
+			name = normalize(name)
+			if name != "" && !seen[name] {
+				seen[name] = true
+				c.names[name] = append(c.names[name], i)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func (c *Client) loadAdmin1() error {
+	f, err := os.Open(c.admin1File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) >= 2 {
+			c.admin1[fields[0]] = fields[1]
+		}
+	}
+	return scanner.Err()
+}
+
+func normalize(str string) string {
+	return strings.ToLower(strings.TrimSpace(str))
+}
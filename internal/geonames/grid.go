@@ -0,0 +1,71 @@
+package geonames
+
+import (
+	"github.com/evanhutnik/wipercheck-service/internal/common"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"math"
+)
+
+// cellKm is roughly the north-south size of a 1 degree grid cell
+const cellKm = 111.0
+
+type cell struct {
+	lat int
+	lon int
+}
+
+// grid is a spatial index bucketing places into 1 degree cells
+type grid struct {
+	cells map[cell][]int
+}
+
+func newGrid(places []Place) *grid {
+	g := &grid{cells: make(map[cell][]int)}
+	for i, place := range places {
+		key := cellOf(place.Latitude, place.Longitude)
+		g.cells[key] = append(g.cells[key], i)
+	}
+	return g
+}
+
+func cellOf(lat float64, lon float64) cell {
+	return cell{lat: int(math.Floor(lat)), lon: int(math.Floor(lon))}
+}
+
+// nearest searches rings of cells outwards from the coordinates, returning the index of the nearest place
+// with at least the minimum population or -1 if there are none
+func (g *grid) nearest(places []Place, coords t.Coordinates, minPopulation int64) int {
+	origin := cellOf(coords.Latitude, coords.Longitude)
+	best, bestDist := -1, math.Inf(1)
+	// cells are narrower away from the equator, so using their east-west size to know when to stop searching
+	ringKm := cellKm * math.Max(math.Cos((math.Abs(coords.Latitude)+1)*math.Pi/180), 0.01)
+	// stopping once the ring is further away than the best match, or after searching the whole globe
+	for ring := 0; ring <= 180; ring++ {
+		if best >= 0 && float64(ring-1)*ringKm > bestDist {
+			break
+		}
+		for lat := origin.lat - ring; lat <= origin.lat+ring; lat++ {
+			for lon := origin.lon - ring; lon <= origin.lon+ring; lon++ {
+				// only visiting the cells on the edge of the ring
+				if lat != origin.lat-ring && lat != origin.lat+ring && lon != origin.lon-ring && lon != origin.lon+ring {
+					continue
+				}
+				for _, i := range g.cells[cell{lat: lat, lon: wrapLon(lon)}] {
+					if places[i].Population < minPopulation {
+						continue
+					}
+					dist := common.Distance(coords, t.Coordinates{Latitude: places[i].Latitude, Longitude: places[i].Longitude})
+					if dist < bestDist {
+						best, bestDist = i, dist
+					}
+				}
+			}
+		}
+	}
+	return best
+}
+
+// wrapLon wraps a cell longitude around the antimeridian
+func wrapLon(lon int) int {
+	return ((lon+180)%360+360)%360 - 180
+}
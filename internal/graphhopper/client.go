@@ -0,0 +1,260 @@
+package graphhopper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/routing"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+type Request struct {
+	// Points are longitude,latitude pairs
+	Points        [][]float64  `json:"points"`
+	Profile       string       `json:"profile"`
+	Instructions  bool         `json:"instructions"`
+	PointsEncoded bool         `json:"points_encoded"`
+	Details       []string     `json:"details,omitempty"`
+	CustomModel   *CustomModel `json:"custom_model,omitempty"`
+	// DisableCH is required for custom models, as they can't use the prepared contraction hierarchies
+	DisableCH bool `json:"ch.disable,omitempty"`
+}
+
+// CustomModel adjusts the routing profile, e.g. to avoid road features
+// https://docs.graphhopper.com/#section/Custom-Model
+type CustomModel struct {
+	Priority []Statement `json:"priority"`
+}
+
+type Statement struct {
+	If         string `json:"if"`
+	MultiplyBy string `json:"multiply_by"`
+}
+
+type Response struct {
+	Paths []Path `json:"paths"`
+	// Message is set instead of the paths when no route could be found
+	Message string `json:"message"`
+}
+
+// Path has the distance in metres and time in milliseconds
+type Path struct {
+	Distance     float64       `json:"distance"`
+	Time         float64       `json:"time"`
+	Points       Points        `json:"points"`
+	Instructions []Instruction `json:"instructions"`
+	// Details are the requested path details, as lists of [from, to, value] intervals of the points
+	Details map[string][][]interface{} `json:"details"`
+}
+
+// Points is a GeoJSON LineString, with coordinates as longitude,latitude pairs
+type Points struct {
+	Type        string      `json:"type"`
+	Coordinates [][]float64 `json:"coordinates"`
+}
+
+type Instruction struct {
+	Text       string  `json:"text"`
+	StreetName string  `json:"street_name"`
+	Distance   float64 `json:"distance"`
+	Time       float64 `json:"time"`
+	// Interval is the range of indexes of the instruction's points in the path
+	Interval []int `json:"interval"`
+	Sign     int   `json:"sign"`
+}
+
+type ClientOption func(*Client)
+
+// profiles are graphhopper's routing profiles for each vehicle. Motorcycles have no profile
+// https://docs.graphhopper.com/#section/Map-Data-and-Routing-Profiles
+var profiles = map[string]string{
+	routing.VehicleCar:     "car",
+	routing.VehicleTruck:   "truck",
+	routing.VehicleBicycle: "bike",
+	routing.VehicleFoot:    "foot",
+}
+
+type Client struct {
+	apiKey  string
+	baseUrl string
+}
+
+// ApiKeyOption sets the api key, which is only needed for the hosted GraphHopper api
+func ApiKeyOption(apiKey string) ClientOption {
+	return func(c *Client) {
+		c.apiKey = apiKey
+	}
+}
+
+func BaseUrlOption(baseUrl string) ClientOption {
+	return func(c *Client) {
+		c.baseUrl = baseUrl
+	}
+}
+
+func New(opts ...ClientOption) *Client {
+	c := &Client{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.baseUrl == "" {
+		panic("Missing baseUrl in graphhopper client")
+	}
+	return c
+}
+
+func (c *Client) Supports(vehicle string) bool {
+	_, ok := profiles[vehicle]
+	return ok
+}
+
+// SupportsAvoid only allows avoiding ferries for bicycles and pedestrians, as their profiles have no toll information
+// and they can't use motorways anyway
+func (c *Client) SupportsAvoid(vehicle string, avoid []string) bool {
+	for _, feature := range avoid {
+		if feature != routing.AvoidFerries && !routing.Motorized(vehicle) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Client) Route(ctx context.Context, trip *t.Trip, opts routing.Options) (*t.Route, error) {
+	vehicle := opts.Vehicle
+	if vehicle == "" {
+		vehicle = routing.VehicleCar
+	}
+	profile, ok := profiles[vehicle]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("no graphhopper profile for vehicle %v", vehicle))
+	}
+	req, err := url.Parse(fmt.Sprintf("%v/route", c.baseUrl))
+	if err != nil {
+		err = errors.New(fmt.Sprintf("failed to parse graphhopper baseUrl %s: %s", c.baseUrl, err.Error()))
+		return nil, err
+	}
+	if c.apiKey != "" {
+		q := req.Query()
+		q.Add("key", c.apiKey)
+		req.RawQuery = q.Encode()
+	}
+
+	points := [][]float64{{trip.From.Longitude, trip.From.Latitude}}
+	for _, via := range opts.Via {
+		points = append(points, []float64{via.Longitude, via.Latitude})
+	}
+	points = append(points, []float64{trip.To.Longitude, trip.To.Latitude})
+	routeReq := Request{
+		Points:        points,
+		Profile:       profile,
+		Instructions:  true,
+		PointsEncoded: false,
+		Details:       []string{"road_environment"},
+	}
+	if model := avoidModel(opts); model != nil {
+		routeReq.CustomModel = model
+		routeReq.DisableCH = true
+	}
+	reqBody, _ := json.Marshal(routeReq)
+	ctxReq, _ := http.NewRequestWithContext(ctx, "POST", req.String(), bytes.NewReader(reqBody))
+	ctxReq.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(ctxReq)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error on graphhopper api request: %s", err.Error()))
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error reading graphhopper response body: %s", err.Error()))
+		return nil, err
+	}
+
+	var respObj Response
+	err = json.Unmarshal(body, &respObj)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error unmarshalling response from graphhopper: %s", err.Error()))
+		return nil, err
+	} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = errors.New(fmt.Sprintf("error code %d returned from graphhopper: %s", resp.StatusCode, respObj.Message))
+		return nil, err
+	} else if len(respObj.Paths) == 0 {
+		return nil, errors.New("no route returned from graphhopper")
+	}
+
+	path := respObj.Paths[0]
+	var geometry []t.Coordinates
+	for _, coords := range path.Points.Coordinates {
+		geometry = append(geometry, t.Coordinates{Latitude: coords[1], Longitude: coords[0]})
+	}
+	return &t.Route{
+		Steps:    c.routeStepsFromGraphHopper(path.Instructions, geometry, ferryIntervals(path.Details["road_environment"])),
+		Geometry: geometry,
+		Duration: path.Time / 1000,
+		Distance: path.Distance,
+	}, nil
+}
+
+// avoidModel returns the custom model avoiding the road features, or nil if none are avoided
+func avoidModel(opts routing.Options) *CustomModel {
+	var priority []Statement
+	if opts.Avoids(routing.AvoidTolls) {
+		priority = append(priority, Statement{If: "toll != NO", MultiplyBy: "0"})
+	}
+	if opts.Avoids(routing.AvoidFerries) {
+		priority = append(priority, Statement{If: "road_environment == FERRY", MultiplyBy: "0"})
+	}
+	if opts.Avoids(routing.AvoidMotorways) {
+		priority = append(priority, Statement{If: "road_class == MOTORWAY", MultiplyBy: "0"})
+	}
+	if len(priority) == 0 {
+		return nil
+	}
+	return &CustomModel{Priority: priority}
+}
+
+// ferryIntervals returns the [from, to] point intervals of the path on a ferry from its road_environment details
+func ferryIntervals(details [][]interface{}) [][2]int {
+	var intervals [][2]int
+	for _, detail := range details {
+		if len(detail) != 3 || detail[2] != "ferry" {
+			continue
+		}
+		from, fromOk := detail[0].(float64)
+		to, toOk := detail[1].(float64)
+		if fromOk && toOk {
+			intervals = append(intervals, [2]int{int(from), int(to)})
+		}
+	}
+	return intervals
+}
+
+// routeStepsFromGraphHopper converts instructions to steps, located at the first point of each instruction
+func (c *Client) routeStepsFromGraphHopper(instructions []Instruction, geometry []t.Coordinates, ferries [][2]int) []t.Step {
+	var routeSteps []t.Step
+	for _, instruction := range instructions {
+		if len(instruction.Interval) == 0 || instruction.Interval[0] >= len(geometry) {
+			continue
+		}
+		var mode string
+		for _, ferry := range ferries {
+			if instruction.Interval[0] >= ferry[0] && instruction.Interval[0] < ferry[1] {
+				mode = routing.ModeFerry
+			}
+		}
+		routeSteps = append(routeSteps, t.Step{
+			Name:         instruction.StreetName,
+			Mode:         mode,
+			StepDuration: instruction.Time / 1000,
+			StepDistance: instruction.Distance,
+			Coordinates:  geometry[instruction.Interval[0]],
+		})
+	}
+	return routeSteps
+}
@@ -0,0 +1,159 @@
+package graphhopper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/routing"
+	"github.com/evanhutnik/wipercheck-service/internal/testutil"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var trip = &t.Trip{
+	From: &t.Coordinates{Latitude: 47.6062, Longitude: -122.3321},
+	To:   &t.Coordinates{Latitude: 47.6257, Longitude: -122.5212},
+}
+
+// graphhopperServer stubs the route endpoint with a recorded response, passing each request and its decoded body to check
+func graphhopperServer(tt *testing.T, status int, file string, check func(r *http.Request, req Request)) *httptest.Server {
+	tt.Helper()
+	body := testutil.Testdata(tt, file)
+	return testutil.Server(tt, func(r *http.Request, _ http.Header) (int, []byte) {
+		if r.Method != http.MethodPost || r.URL.Path != "/route" {
+			tt.Errorf("unexpected request %v %v", r.Method, r.URL)
+		}
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			tt.Errorf("invalid request body: %v", err)
+		}
+		check(r, req)
+		return status, body
+	})
+}
+
+func TestRoute(tt *testing.T) {
+	via := t.Coordinates{Latitude: 47.6049, Longitude: -122.3354}
+	server := graphhopperServer(tt, http.StatusOK, "route_ferry.json", func(r *http.Request, req Request) {
+		if key := r.URL.Query().Get("key"); key != "secret" {
+			tt.Errorf("expected the api key, got %q", key)
+		}
+		if req.Profile != "car" {
+			tt.Errorf("expected car profile, got %q", req.Profile)
+		}
+		if !req.Instructions || req.PointsEncoded {
+			tt.Errorf("expected instructions and unencoded points, got %+v", req)
+		}
+		if fmt.Sprint(req.Details) != "[road_environment]" {
+			tt.Errorf("expected road_environment details, got %v", req.Details)
+		}
+		if req.CustomModel != nil || req.DisableCH {
+			tt.Errorf("expected no custom model, got %+v", req)
+		}
+		want := [][]float64{{-122.3321, 47.6062}, {-122.3354, 47.6049}, {-122.5212, 47.6257}}
+		if fmt.Sprint(req.Points) != fmt.Sprint(want) {
+			tt.Errorf("expected points %v, got %v", want, req.Points)
+		}
+	})
+	c := New(BaseUrlOption(server.URL), ApiKeyOption("secret"))
+
+	route, err := c.Route(context.Background(), trip, routing.Options{Via: []t.Coordinates{via}})
+	if err != nil {
+		tt.Fatalf("unexpected error: %v", err)
+	}
+	if route.Distance != 15674 || route.Duration != 2328.8 {
+		tt.Errorf("expected 15674 m in 2328.8 s, got %v m in %v s", route.Distance, route.Duration)
+	}
+	if len(route.Geometry) != 8 || route.Geometry[1] != via {
+		tt.Errorf("expected the path's points as latitude,longitude, got %v", route.Geometry)
+	}
+
+	want := []t.Step{
+		{Name: "Madison Street", StepDistance: 320.5, StepDuration: 51.118, Coordinates: *trip.From},
+		{Coordinates: via},
+		{Name: "Alaskan Way", StepDistance: 330.2, StepDuration: 62.447, Coordinates: via},
+		{Name: "Bainbridge Island - Seattle", Mode: routing.ModeFerry, StepDistance: 14102.9, StepDuration: 2100, Coordinates: t.Coordinates{Latitude: 47.6028, Longitude: -122.3386}},
+		{Name: "Olympic Drive Southeast", StepDistance: 371.8, StepDuration: 45.201, Coordinates: t.Coordinates{Latitude: 47.6229, Longitude: -122.5098}},
+		{Name: "Winslow Way East", StepDistance: 548.6, StepDuration: 70.034, Coordinates: t.Coordinates{Latitude: 47.6241, Longitude: -122.514}},
+		{Coordinates: *trip.To},
+	}
+	if len(route.Steps) != len(want) {
+		tt.Fatalf("expected %d steps, got %+v", len(want), route.Steps)
+	}
+	for i, step := range route.Steps {
+		if step.Name != want[i].Name || step.Mode != want[i].Mode || step.StepDistance != want[i].StepDistance ||
+			step.StepDuration != want[i].StepDuration || step.Coordinates != want[i].Coordinates {
+			tt.Errorf("step %d: expected %+v, got %+v", i, want[i], step)
+		}
+	}
+}
+
+func TestRouteAvoid(tt *testing.T) {
+	server := graphhopperServer(tt, http.StatusOK, "route_ferry.json", func(r *http.Request, req Request) {
+		if r.URL.Query().Has("key") {
+			tt.Errorf("expected no api key, got %q", r.URL.RawQuery)
+		}
+		if req.Profile != "truck" {
+			tt.Errorf("expected truck profile, got %q", req.Profile)
+		}
+		if req.CustomModel == nil || !req.DisableCH {
+			tt.Errorf("expected a custom model without contraction hierarchies, got %+v", req)
+			return
+		}
+		want := []Statement{
+			{If: "toll != NO", MultiplyBy: "0"},
+			{If: "road_environment == FERRY", MultiplyBy: "0"},
+			{If: "road_class == MOTORWAY", MultiplyBy: "0"},
+		}
+		if fmt.Sprint(req.CustomModel.Priority) != fmt.Sprint(want) {
+			tt.Errorf("expected priority %v, got %v", want, req.CustomModel.Priority)
+		}
+	})
+	c := New(BaseUrlOption(server.URL))
+
+	opts := routing.Options{Vehicle: routing.VehicleTruck, Avoid: routing.Avoidable}
+	if _, err := c.Route(context.Background(), trip, opts); err != nil {
+		tt.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRouteError(tt *testing.T) {
+	server := graphhopperServer(tt, http.StatusBadRequest, "route_error.json", func(r *http.Request, req Request) {})
+	c := New(BaseUrlOption(server.URL))
+
+	_, err := c.Route(context.Background(), trip, routing.Options{})
+	if err == nil || !strings.Contains(err.Error(), "Cannot find point 1") {
+		tt.Errorf("expected graphhopper's error when no route is found, got %v", err)
+	}
+	if _, err := c.Route(context.Background(), trip, routing.Options{Vehicle: routing.VehicleMotorcycle}); err == nil {
+		tt.Error("expected an error for a vehicle without a profile")
+	}
+}
+
+func TestSupports(tt *testing.T) {
+	c := New(BaseUrlOption("http://localhost"))
+	for _, vehicle := range []string{routing.VehicleCar, routing.VehicleTruck, routing.VehicleBicycle, routing.VehicleFoot} {
+		if !c.Supports(vehicle) {
+			tt.Errorf("expected %v to be supported", vehicle)
+		}
+	}
+	if c.Supports(routing.VehicleMotorcycle) {
+		tt.Error("expected motorcycles to be unsupported")
+	}
+}
+
+func TestSupportsAvoid(tt *testing.T) {
+	c := New(BaseUrlOption("http://localhost"))
+	if !c.SupportsAvoid(routing.VehicleTruck, routing.Avoidable) {
+		tt.Error("expected trucks to avoid all features")
+	}
+	if !c.SupportsAvoid(routing.VehicleBicycle, []string{routing.AvoidFerries}) {
+		tt.Error("expected bicycles to avoid ferries")
+	}
+	if c.SupportsAvoid(routing.VehicleFoot, []string{routing.AvoidFerries, routing.AvoidTolls}) {
+		tt.Error("expected pedestrians not to avoid tolls")
+	}
+}
@@ -0,0 +1,155 @@
+package i18n
+
+// catalogs contains the service-generated messages for each supported language, keyed by message key
+var catalogs = map[string]map[string]string{
+	"en": {
+		"briefing.dry.whole":      "Expect dry roads for the whole trip",
+		"briefing.dry.until":      "Expect dry roads until %v",
+		"briefing.wet.whole":      "Expect %v for the whole trip",
+		"briefing.wet.duration":   "Expect %v for about %v",
+		"briefing.possible.start": "%v is possible at the start",
+		"briefing.then.dry":       "then dry roads after %v",
+		"briefing.then.rest":      "then %v for the rest of the trip",
+		"briefing.then.duration":  "then %v for about %v",
+		"briefing.possible.after": "%v is possible after %v around %v",
+		"precipitation":           "precipitation",
+		"hazard.conditions":       "%v forecast",
+		"hazard.pop":              "%d%% chance of precipitation",
+		"hazard.wind":             "sustained winds of %v km/h",
+		"hazard.gust":             "gusts up to %v km/h",
+		"duration.minutes":        "%d minutes",
+		"duration.hour":           "1 hour",
+		"duration.hours":          "%d hours",
+		"duration.hoursMinutes":   "%v %d minutes",
+		"time.hour":               "3 PM",
+		"time.hourMinute":         "3:04 PM",
+		"conditions.200":          "thunderstorm with light rain",
+		"conditions.201":          "thunderstorm with rain",
+		"conditions.202":          "thunderstorm with heavy rain",
+		"conditions.211":          "thunderstorm",
+		"conditions.300":          "light intensity drizzle",
+		"conditions.301":          "drizzle",
+		"conditions.302":          "heavy intensity drizzle",
+		"conditions.500":          "light rain",
+		"conditions.501":          "moderate rain",
+		"conditions.502":          "heavy intensity rain",
+		"conditions.511":          "freezing rain",
+		"conditions.520":          "light intensity shower rain",
+		"conditions.521":          "shower rain",
+		"conditions.522":          "heavy intensity shower rain",
+		"conditions.600":          "light snow",
+		"conditions.601":          "snow",
+		"conditions.602":          "heavy snow",
+		"conditions.611":          "sleet",
+		"conditions.616":          "rain and snow",
+		"conditions.620":          "light shower snow",
+		"conditions.622":          "heavy shower snow",
+		"conditions.701":          "mist",
+		"conditions.741":          "fog",
+		"conditions.800":          "clear sky",
+		"conditions.801":          "few clouds",
+		"conditions.802":          "scattered clouds",
+		"conditions.803":          "broken clouds",
+		"conditions.804":          "overcast clouds",
+	},
+	"fr": {
+		"briefing.dry.whole":      "Routes sèches pendant tout le trajet",
+		"briefing.dry.until":      "Routes sèches jusqu'à %v",
+		"briefing.wet.whole":      "Prévoir %v pendant tout le trajet",
+		"briefing.wet.duration":   "Prévoir %v pendant environ %v",
+		"briefing.possible.start": "%v possible au départ",
+		"briefing.then.dry":       "puis routes sèches après %v",
+		"briefing.then.rest":      "puis %v jusqu'à la fin du trajet",
+		"briefing.then.duration":  "puis %v pendant environ %v",
+		"briefing.possible.after": "%v possible après %v vers %v",
+		"precipitation":           "précipitations",
+		"hazard.conditions":       "prévision : %v",
+		"hazard.pop":              "%d %% de risque de précipitations",
+		"hazard.wind":             "vents soutenus de %v km/h",
+		"hazard.gust":             "rafales jusqu'à %v km/h",
+		"duration.minutes":        "%d minutes",
+		"duration.hour":           "1 heure",
+		"duration.hours":          "%d heures",
+		"duration.hoursMinutes":   "%v %d minutes",
+		"time.hour":               "15 h",
+		"time.hourMinute":         "15 h 04",
+		"conditions.200":          "orage avec pluie légère",
+		"conditions.201":          "orage avec pluie",
+		"conditions.202":          "orage avec fortes pluies",
+		"conditions.211":          "orage",
+		"conditions.300":          "bruine légère",
+		"conditions.301":          "bruine",
+		"conditions.302":          "forte bruine",
+		"conditions.500":          "pluie légère",
+		"conditions.501":          "pluie modérée",
+		"conditions.502":          "forte pluie",
+		"conditions.511":          "pluie verglaçante",
+		"conditions.520":          "averses légères",
+		"conditions.521":          "averses",
+		"conditions.522":          "fortes averses",
+		"conditions.600":          "légères chutes de neige",
+		"conditions.601":          "neige",
+		"conditions.602":          "fortes chutes de neige",
+		"conditions.611":          "neige fondue",
+		"conditions.616":          "pluie et neige",
+		"conditions.620":          "averses de neige légères",
+		"conditions.622":          "fortes averses de neige",
+		"conditions.701":          "brume",
+		"conditions.741":          "brouillard",
+		"conditions.800":          "ciel dégagé",
+		"conditions.801":          "peu nuageux",
+		"conditions.802":          "partiellement nuageux",
+		"conditions.803":          "nuageux",
+		"conditions.804":          "couvert",
+	},
+	"es": {
+		"briefing.dry.whole":      "Carreteras secas durante todo el viaje",
+		"briefing.dry.until":      "Carreteras secas hasta %v",
+		"briefing.wet.whole":      "Se espera %v durante todo el viaje",
+		"briefing.wet.duration":   "Se espera %v durante aproximadamente %v",
+		"briefing.possible.start": "%v posible al inicio",
+		"briefing.then.dry":       "luego carreteras secas después de %v",
+		"briefing.then.rest":      "luego %v hasta el final del viaje",
+		"briefing.then.duration":  "luego %v durante aproximadamente %v",
+		"briefing.possible.after": "%v posible después de %v hacia las %v",
+		"precipitation":           "precipitación",
+		"hazard.conditions":       "se prevé %v",
+		"hazard.pop":              "%d %% de probabilidad de precipitación",
+		"hazard.wind":             "vientos sostenidos de %v km/h",
+		"hazard.gust":             "ráfagas de hasta %v km/h",
+		"duration.minutes":        "%d minutos",
+		"duration.hour":           "1 hora",
+		"duration.hours":          "%d horas",
+		"duration.hoursMinutes":   "%v y %d minutos",
+		"time.hour":               "15:04",
+		"time.hourMinute":         "15:04",
+		"conditions.200":          "tormenta con lluvia ligera",
+		"conditions.201":          "tormenta con lluvia",
+		"conditions.202":          "tormenta con lluvia intensa",
+		"conditions.211":          "tormenta",
+		"conditions.300":          "llovizna ligera",
+		"conditions.301":          "llovizna",
+		"conditions.302":          "llovizna intensa",
+		"conditions.500":          "lluvia ligera",
+		"conditions.501":          "lluvia moderada",
+		"conditions.502":          "lluvia intensa",
+		"conditions.511":          "lluvia helada",
+		"conditions.520":          "chubascos ligeros",
+		"conditions.521":          "chubascos",
+		"conditions.522":          "chubascos intensos",
+		"conditions.600":          "nevada ligera",
+		"conditions.601":          "nieve",
+		"conditions.602":          "nevada intensa",
+		"conditions.611":          "aguanieve",
+		"conditions.616":          "lluvia y nieve",
+		"conditions.620":          "chubascos de nieve ligeros",
+		"conditions.622":          "chubascos de nieve intensos",
+		"conditions.701":          "neblina",
+		"conditions.741":          "niebla",
+		"conditions.800":          "cielo despejado",
+		"conditions.801":          "algunas nubes",
+		"conditions.802":          "nubes dispersas",
+		"conditions.803":          "nublado",
+		"conditions.804":          "cubierto",
+	},
+}
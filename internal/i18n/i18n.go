@@ -0,0 +1,40 @@
+package i18n
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultLang is the language used when no language is requested or a message is missing from a catalog
+const DefaultLang = "en"
+
+// Supported returns whether a message catalog exists for the language
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Sprintf formats the message with the given key from the language's catalog, falling back to the default language
+func Sprintf(lang string, key string, args ...interface{}) string {
+	msg, ok := catalogs[lang][key]
+	if !ok {
+		msg, ok = catalogs[DefaultLang][key]
+		if !ok {
+			msg = key
+		}
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Capitalize upper-cases the first letter of a string, handling multi-byte characters
+func Capitalize(str string) string {
+	r, size := utf8.DecodeRuneInString(str)
+	if r == utf8.RuneError {
+		return str
+	}
+	return string(unicode.ToTitle(r)) + str[size:]
+}
@@ -0,0 +1,309 @@
+package metno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+	"github.com/evanhutnik/wipercheck-service/internal/weather"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Response struct {
+	Properties Properties `json:"properties"`
+}
+
+type Properties struct {
+	Timeseries []Timeseries `json:"timeseries"`
+}
+
+type Timeseries struct {
+	Time string `json:"time"`
+	Data Data   `json:"data"`
+}
+
+type Data struct {
+	Instant    Instant `json:"instant"`
+	Next1Hours *Period `json:"next_1_hours"`
+}
+
+type Instant struct {
+	Details InstantDetails `json:"details"`
+}
+
+type InstantDetails struct {
+	AirTemperature  float64 `json:"air_temperature"`
+	WindSpeed       float64 `json:"wind_speed"`
+	WindSpeedOfGust float64 `json:"wind_speed_of_gust"`
+}
+
+type Period struct {
+	Summary Summary       `json:"summary"`
+	Details PeriodDetails `json:"details"`
+}
+
+type Summary struct {
+	SymbolCode string `json:"symbol_code"`
+}
+
+type PeriodDetails struct {
+	PrecipitationAmount        float64  `json:"precipitation_amount"`
+	ProbabilityOfPrecipitation *float64 `json:"probability_of_precipitation"`
+}
+
+const (
+	// maxForecasts bounds the number of cached forecasts
+	maxForecasts = 1000
+	// halfPopAmount is the hourly precipitation amount in mm given a 50% chance when no probability is available
+	halfPopAmount = 0.2
+)
+
+// fetch is an in-flight forecast request, which concurrent requests for the same point wait for
+type fetch struct {
+	done     chan struct{}
+	response *Response
+	err      error
+}
+
+// cachedForecast is a forecast kept until it expires, as required by the met.no terms of service
+type cachedForecast struct {
+	response     Response
+	expires      time.Time
+	lastModified string
+}
+
+type ClientOption func(*Client)
+
+type Client struct {
+	baseUrl   string
+	userAgent string
+
+	cacheMu sync.Mutex
+	cache   map[string]*cachedForecast
+	fetches map[string]*fetch
+}
+
+func BaseUrlOption(baseUrl string) ClientOption {
+	return func(c *Client) {
+		c.baseUrl = baseUrl
+	}
+}
+
+func UserAgentOption(userAgent string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+func New(opts ...ClientOption) *Client {
+	c := &Client{
+		cache:   make(map[string]*cachedForecast),
+		fetches: make(map[string]*fetch),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.baseUrl == "" {
+		panic("Missing baseUrl in metno client")
+	}
+	// met.no blocks requests without a User-Agent identifying the application
+	if c.userAgent == "" {
+		panic("Missing userAgent in metno client")
+	}
+	return c
+}
+
+func (c *Client) GetHourlyWeather(ctx context.Context, coords types.Coordinates, time int64, lang string) (*types.Weather, error) {
+	weatherData, err := c.GetWeather(ctx, coords.Latitude, coords.Longitude, lang)
+	if err != nil {
+		return nil, err
+	}
+	for _, hourly := range weatherData {
+		if hourly.Time == time {
+			return &hourly, nil
+		}
+	}
+	return nil, weather.ErrOutOfRange
+}
+
+func (c *Client) GetWeather(ctx context.Context, lat float64, long float64, lang string) ([]types.Weather, error) {
+	respObj, err := c.forecast(ctx, lat, long)
+	if err != nil {
+		return nil, err
+	}
+	return c.hourlyWeatherFromMetno(respObj.Properties.Timeseries, lang), nil
+}
+
+// forecast returns the forecast for the coordinates, only requesting it again once the cached forecast has expired.
+// Concurrent requests for the same point share a single request
+func (c *Client) forecast(ctx context.Context, lat float64, long float64) (*Response, error) {
+	// met.no asks for coordinates to have at most 4 decimals to make use of its caching
+	lat, long = round(lat), round(long)
+	key := fmt.Sprintf("%v,%v", lat, long)
+
+	c.cacheMu.Lock()
+	cached := c.cache[key]
+	// cached forecasts are replaced rather than modified, so they are safe to read without the lock
+	if cached != nil && time.Now().Before(cached.expires) {
+		c.cacheMu.Unlock()
+		return &cached.response, nil
+	}
+	f, inFlight := c.fetches[key]
+	if !inFlight {
+		f = &fetch{done: make(chan struct{})}
+		c.fetches[key] = f
+	}
+	c.cacheMu.Unlock()
+
+	if inFlight {
+		select {
+		case <-f.done:
+			return f.response, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f.response, f.err = c.request(ctx, key, lat, long, cached)
+	c.cacheMu.Lock()
+	delete(c.fetches, key)
+	c.cacheMu.Unlock()
+	close(f.done)
+	return f.response, f.err
+}
+
+// request requests the forecast for the coordinates and caches it, revalidating the expired forecast if there is one
+func (c *Client) request(ctx context.Context, key string, lat float64, long float64, cached *cachedForecast) (*Response, error) {
+	req, err := url.Parse(c.baseUrl)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("failed to parse baseUrl %s: %s", c.baseUrl, err.Error()))
+		return nil, err
+	}
+	q := req.Query()
+	q.Add("lat", strconv.FormatFloat(lat, 'f', -1, 64))
+	q.Add("lon", strconv.FormatFloat(long, 'f', -1, 64))
+	req.RawQuery = q.Encode()
+
+	ctxReq, _ := http.NewRequestWithContext(ctx, "GET", req.String(), nil)
+	ctxReq.Header.Set("User-Agent", c.userAgent)
+	if cached != nil && cached.lastModified != "" {
+		ctxReq.Header.Set("If-Modified-Since", cached.lastModified)
+	}
+	resp, err := http.DefaultClient.Do(ctxReq)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error on metno api request: %s", err.Error()))
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		c.cacheMu.Lock()
+		c.put(key, &cachedForecast{
+			response:     cached.response,
+			expires:      expires(resp),
+			lastModified: cached.lastModified,
+		})
+		c.cacheMu.Unlock()
+		return &cached.response, nil
+	} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = errors.New(fmt.Sprintf("error code %d returned from metno", resp.StatusCode))
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error reading metno response body: %s", err.Error()))
+		return nil, err
+	}
+
+	var respObj Response
+	err = json.Unmarshal(body, &respObj)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error unmarshalling response from metno: %s", err.Error()))
+		return nil, err
+	}
+
+	c.cacheMu.Lock()
+	c.put(key, &cachedForecast{
+		response:     respObj,
+		expires:      expires(resp),
+		lastModified: resp.Header.Get("Last-Modified"),
+	})
+	c.cacheMu.Unlock()
+	return &respObj, nil
+}
+
+// put caches the forecast, dropping expired forecasts and then arbitrary ones once full
+func (c *Client) put(key string, forecast *cachedForecast) {
+	if len(c.cache) >= maxForecasts {
+		now := time.Now()
+		for k, cached := range c.cache {
+			if now.After(cached.expires) {
+				delete(c.cache, k)
+			}
+		}
+		for k := range c.cache {
+			if len(c.cache) < maxForecasts {
+				break
+			}
+			delete(c.cache, k)
+		}
+	}
+	c.cache[key] = forecast
+}
+
+func (c *Client) hourlyWeatherFromMetno(timeseries []Timeseries, lang string) []types.Weather {
+	var hourly []types.Weather
+	for _, ts := range timeseries {
+		// only the first days of the forecast have hourly periods
+		if ts.Data.Next1Hours == nil {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, ts.Time)
+		if err != nil {
+			continue
+		}
+		next := ts.Data.Next1Hours
+		w := types.Weather{
+			Time: start.Unix(),
+			Temp: ts.Data.Instant.Details.AirTemperature,
+			// converting wind from m/s to km/h
+			WindSpeed:  ts.Data.Instant.Details.WindSpeed * 3.6,
+			WindGust:   ts.Data.Instant.Details.WindSpeedOfGust * 3.6,
+			Conditions: conditionsFromSymbol(next.Summary.SymbolCode, lang),
+		}
+		if next.Details.ProbabilityOfPrecipitation != nil {
+			// met.no returns a percentage while the service uses a fraction
+			w.Pop = *next.Details.ProbabilityOfPrecipitation / 100
+		} else if amount := next.Details.PrecipitationAmount; amount > 0 {
+			// the compact format has no probability, so estimating the chance from the expected amount instead,
+			// rising from 0 for no precipitation through 50% at halfPopAmount towards 100% for heavy precipitation.
+			// This is only a grade of how wet the hour is, and not a calibrated probability
+			w.Pop = amount / (amount + halfPopAmount)
+		}
+		hourly = append(hourly, w)
+	}
+	return hourly
+}
+
+// expires returns when the response should be requested again, based on its Expires header
+func expires(resp *http.Response) time.Time {
+	expiry, err := http.ParseTime(resp.Header.Get("Expires"))
+	if err != nil {
+		return time.Now().Add(10 * time.Minute)
+	}
+	return expiry
+}
+
+// round rounds the coordinate to 4 decimals, rather than truncating it which can drop a unit to floating point error
+func round(coord float64) float64 {
+	return math.Round(coord*10000) / 10000
+}
@@ -0,0 +1,186 @@
+package metno
+
+import (
+	"context"
+	"github.com/evanhutnik/wipercheck-service/internal/testutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const forecastBody = `{"properties": {"timeseries": [
+	{"time": "2024-01-15T09:00:00Z", "data": {
+		"instant": {"details": {"air_temperature": -3.4, "wind_speed": 5, "wind_speed_of_gust": 10}},
+		"next_1_hours": {"summary": {"symbol_code": "lightsnow"}, "details": {"precipitation_amount": 0.6, "probability_of_precipitation": 70}}
+	}},
+	{"time": "2024-01-15T10:00:00Z", "data": {
+		"instant": {"details": {"air_temperature": -2.9, "wind_speed": 4}},
+		"next_1_hours": {"summary": {"symbol_code": "cloudy"}, "details": {"precipitation_amount": 0}}
+	}},
+	{"time": "2024-01-22T12:00:00Z", "data": {
+		"instant": {"details": {"air_temperature": 1.2, "wind_speed": 3}}
+	}}
+]}}`
+
+// metnoServer stubs the forecast endpoint, answering revalidations with 304 Not Modified if notModified is set
+func metnoServer(t *testing.T, requests *int32, expires string, notModified bool) *httptest.Server {
+	t.Helper()
+	return testutil.Server(t, func(r *http.Request, header http.Header) (int, []byte) {
+		atomic.AddInt32(requests, 1)
+		if r.Header.Get("User-Agent") != "wipercheck-test" {
+			t.Errorf("missing User-Agent header, got %q", r.Header.Get("User-Agent"))
+		}
+		if expires != "" {
+			header.Set("Expires", expires)
+		}
+		header.Set("Last-Modified", "Mon, 15 Jan 2024 08:30:00 GMT")
+		if notModified && r.Header.Get("If-Modified-Since") != "" {
+			return http.StatusNotModified, nil
+		}
+		return http.StatusOK, []byte(forecastBody)
+	})
+}
+
+func TestGetWeather(t *testing.T) {
+	var requests int32
+	server := metnoServer(t, &requests, "", false)
+	c := New(BaseUrlOption(server.URL), UserAgentOption("wipercheck-test"))
+
+	hourly, err := c.GetWeather(context.Background(), 59.9139, 10.7522, "en")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// only the hours with a next_1_hours period are included
+	if len(hourly) != 2 {
+		t.Fatalf("expected 2 hours, got %d", len(hourly))
+	}
+	first := hourly[0]
+	if first.Time != time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC).Unix() || first.Temp != -3.4 {
+		t.Errorf("unexpected first hour %+v", first)
+	}
+	// 5 and 10 m/s
+	if first.WindSpeed != 18 || first.WindGust != 36 {
+		t.Errorf("expected 18 km/h with 36 km/h gusts, got %v and %v", first.WindSpeed, first.WindGust)
+	}
+	if first.Conditions.Id != 600 {
+		t.Errorf("expected light snow, got %+v", first.Conditions)
+	}
+}
+
+func TestForecastExpires(t *testing.T) {
+	tests := []struct {
+		name        string
+		expires     string
+		notModified bool
+		requests    int32
+	}{
+		{"fresh", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), false, 1},
+		{"no expires header", "", false, 1},
+		{"expired", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), false, 2},
+		{"expired and not modified", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), true, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int32
+			server := metnoServer(t, &requests, test.expires, test.notModified)
+			c := New(BaseUrlOption(server.URL), UserAgentOption("wipercheck-test"))
+
+			for i := 0; i < 2; i++ {
+				hourly, err := c.GetWeather(context.Background(), 59.9139, 10.7522, "en")
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(hourly) != 2 {
+					t.Errorf("expected the cached or revalidated forecast, got %d hours", len(hourly))
+				}
+			}
+			if requests != test.requests {
+				t.Errorf("expected %d requests, got %d", test.requests, requests)
+			}
+		})
+	}
+}
+
+func TestForecastSharesRequests(t *testing.T) {
+	var requests int32
+	arrived, release := make(chan struct{}), make(chan struct{})
+	server := testutil.Server(t, func(r *http.Request, header http.Header) (int, []byte) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			close(arrived)
+		}
+		<-release
+		// expired straight away, so only sharing the request avoids a second one
+		header.Set("Expires", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		return http.StatusOK, []byte(forecastBody)
+	})
+	c := New(BaseUrlOption(server.URL), UserAgentOption("wipercheck-test"))
+
+	wg := new(sync.WaitGroup)
+	get := func() {
+		defer wg.Done()
+		if _, err := c.GetWeather(context.Background(), 59.9139, 10.7522, "en"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	wg.Add(2)
+	go get()
+	<-arrived
+	// a point rounding to the same coordinates, requested while the first request is in flight
+	go get()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if requests != 1 {
+		t.Errorf("expected concurrent requests for a point to be shared, got %d requests", requests)
+	}
+}
+
+func TestPop(t *testing.T) {
+	probability := func(p float64) *float64 { return &p }
+	tests := []struct {
+		name    string
+		details PeriodDetails
+		want    float64
+	}{
+		{"probability", PeriodDetails{PrecipitationAmount: 0.6, ProbabilityOfPrecipitation: probability(70)}, 0.7},
+		{"probability without an amount", PeriodDetails{ProbabilityOfPrecipitation: probability(20)}, 0.2},
+		{"dry", PeriodDetails{}, 0},
+		{"drizzle", PeriodDetails{PrecipitationAmount: 0.05}, 0.2},
+		{"half", PeriodDetails{PrecipitationAmount: halfPopAmount}, 0.5},
+		{"heavy", PeriodDetails{PrecipitationAmount: 1.8}, 0.9},
+	}
+	c := New(BaseUrlOption("http://localhost"), UserAgentOption("wipercheck-test"))
+	for _, test := range tests {
+		timeseries := []Timeseries{{Time: "2024-01-15T09:00:00Z", Data: Data{Next1Hours: &Period{Details: test.details}}}}
+		hourly := c.hourlyWeatherFromMetno(timeseries, "en")
+		if len(hourly) != 1 {
+			t.Fatalf("%v: expected 1 hour, got %d", test.name, len(hourly))
+		}
+		if math.Abs(hourly[0].Pop-test.want) > 1e-9 {
+			t.Errorf("%v: expected a pop of %v, got %v", test.name, test.want, hourly[0].Pop)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		coord float64
+		want  float64
+	}{
+		{59.91391234, 59.9139},
+		{10.75225, 10.7523},
+		// 0.0003*10000 is just under 3, which truncating turned into 0.0002
+		{0.0003, 0.0003},
+		{-74.00605, -74.0061},
+	}
+	for _, test := range tests {
+		if got := round(test.coord); got != test.want {
+			t.Errorf("round(%v) = %v, want %v", test.coord, got, test.want)
+		}
+	}
+}
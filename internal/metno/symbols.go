@@ -0,0 +1,59 @@
+package metno
+
+import (
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+	"github.com/evanhutnik/wipercheck-service/internal/weather"
+	"strings"
+)
+
+// conditionsFromSymbol maps a met.no symbol code such as 'lightrainshowers_day' into conditions
+// https://api.met.no/weatherapi/weathericon/2.0/documentation
+func conditionsFromSymbol(symbol string, lang string) types.Conditions {
+	// the time of day variant doesn't affect the conditions
+	code := symbol
+	if i := strings.Index(code, "_"); i >= 0 {
+		code = code[:i]
+	}
+	showers := strings.Contains(code, "showers")
+
+	var id int
+	switch {
+	case strings.Contains(code, "thunder"):
+		id = intensity(code, 200, 201, 202)
+	case strings.Contains(code, "sleet"):
+		id = 611
+	case strings.Contains(code, "snow") && showers:
+		id = intensity(code, 620, 620, 622)
+	case strings.Contains(code, "snow"):
+		id = intensity(code, 600, 601, 602)
+	case strings.Contains(code, "rain") && showers:
+		id = intensity(code, 520, 521, 522)
+	case strings.Contains(code, "rain"):
+		id = intensity(code, 500, 501, 502)
+	case code == "fog":
+		id = 741
+	case code == "clearsky":
+		id = 800
+	case code == "fair":
+		id = 801
+	case code == "partlycloudy":
+		id = 802
+	case code == "cloudy":
+		id = 804
+	default:
+		return types.Conditions{}
+	}
+	return weather.Conditions(id, lang)
+}
+
+// intensity picks the condition id matching the intensity prefix of the symbol code
+func intensity(code string, light int, moderate int, heavy int) int {
+	switch {
+	case strings.HasPrefix(code, "light"):
+		return light
+	case strings.HasPrefix(code, "heavy"):
+		return heavy
+	default:
+		return moderate
+	}
+}
@@ -0,0 +1,238 @@
+package nominatim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/common"
+	"github.com/evanhutnik/wipercheck-service/internal/geocode"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Place struct {
+	Lat         string  `json:"lat"`
+	Lon         string  `json:"lon"`
+	DisplayName string  `json:"display_name"`
+	Importance  float64 `json:"importance"`
+	Address     Address `json:"address"`
+	Error       string  `json:"error"`
+}
+
+type Address struct {
+	HouseNumber  string `json:"house_number"`
+	Road         string `json:"road"`
+	City         string `json:"city"`
+	Town         string `json:"town"`
+	Village      string `json:"village"`
+	Hamlet       string `json:"hamlet"`
+	Municipality string `json:"municipality"`
+	State        string `json:"state"`
+	Province     string `json:"province"`
+	Country      string `json:"country"`
+	CountryCode  string `json:"country_code"`
+}
+
+type ClientOption func(*Client)
+
+type Client struct {
+	baseUrl   string
+	userAgent string
+	interval  time.Duration
+
+	// nominatim's usage policy limits requests to one per interval, so each request reserves the next free slot
+	mu   sync.Mutex
+	next time.Time
+}
+
+func BaseUrlOption(baseUrl string) ClientOption {
+	return func(c *Client) {
+		c.baseUrl = baseUrl
+	}
+}
+
+func UserAgentOption(userAgent string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// IntervalOption sets the minimum time between requests, which defaults to the 1 second required by the public instance
+func IntervalOption(interval time.Duration) ClientOption {
+	return func(c *Client) {
+		c.interval = interval
+	}
+}
+
+func New(opts ...ClientOption) *Client {
+	c := &Client{
+		interval: time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.baseUrl == "" {
+		panic("Missing baseUrl in nominatim client")
+	}
+	if c.userAgent == "" {
+		panic("Missing userAgent in nominatim client")
+	}
+	return c
+}
+
+func (c *Client) Search(ctx context.Context, query string, opts geocode.SearchOptions) ([]t.Place, error) {
+	limit := opts.Limit
+	if limit < 1 {
+		limit = 1
+	}
+	// free-form queries can't be combined with nominatim's structured fields, so the region is added to the query
+	if opts.Region != "" {
+		query = fmt.Sprintf("%v, %v", query, opts.Region)
+	}
+	q := url.Values{}
+	q.Add("q", query)
+	q.Add("limit", strconv.Itoa(limit))
+	if opts.Country != "" {
+		q.Add("countrycodes", strings.ToLower(opts.Country))
+	}
+	if opts.Near != nil {
+		// preferring results within a degree of the location without excluding results outside of it
+		q.Add("viewbox", fmt.Sprintf("%v,%v,%v,%v", opts.Near.Longitude-1, opts.Near.Latitude+1, opts.Near.Longitude+1, opts.Near.Latitude-1))
+		q.Add("bounded", "0")
+	}
+
+	var results []Place
+	if err := c.get(ctx, "search", q, opts.Lang, &results); err != nil {
+		return nil, err
+	}
+	var places []t.Place
+	for _, result := range results {
+		coords, err := result.coordinates()
+		if err != nil {
+			return nil, err
+		}
+		places = append(places, t.Place{
+			Label:       result.DisplayName,
+			Coordinates: *coords,
+			// importance is roughly between 0 and 1, so it is used as the confidence
+			Confidence: math.Min(math.Max(result.Importance, 0), 1),
+		})
+	}
+	return places, nil
+}
+
+func (c *Client) ReverseGeoCode(ctx context.Context, coords t.Coordinates, lang string) (*t.Location, error) {
+	q := url.Values{}
+	q.Add("lat", strconv.FormatFloat(coords.Latitude, 'f', -1, 64))
+	q.Add("lon", strconv.FormatFloat(coords.Longitude, 'f', -1, 64))
+
+	var place Place
+	if err := c.get(ctx, "reverse", q, lang, &place); err != nil {
+		return nil, err
+	} else if place.Error != "" {
+		return nil, nil
+	}
+	return place.location(), nil
+}
+
+func (c *Client) get(ctx context.Context, endpoint string, q url.Values, lang string, respObj interface{}) error {
+	req, err := url.Parse(fmt.Sprintf("%v/%v", c.baseUrl, endpoint))
+	if err != nil {
+		return errors.New(fmt.Sprintf("failed to parse nominatim baseUrl %s: %s", c.baseUrl, err.Error()))
+	}
+	q.Add("format", "jsonv2")
+	q.Add("addressdetails", "1")
+	if lang != "" {
+		q.Add("accept-language", lang)
+	}
+	req.RawQuery = q.Encode()
+
+	ctxReq, _ := http.NewRequestWithContext(ctx, "GET", req.String(), nil)
+	ctxReq.Header.Set("User-Agent", c.userAgent)
+
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	resp, err := common.GetWithRetry(ctxReq, "nominatim")
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error reading nominatim response body: %s", err.Error()))
+	}
+
+	err = json.Unmarshal(body, respObj)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error unmarshalling response from nominatim: %s", err.Error()))
+	}
+	return nil
+}
+
+// wait reserves the next request slot and sleeps until it, without holding the lock so that
+// requests waiting for later slots don't block each other and can be cancelled
+func (c *Client) wait(ctx context.Context) error {
+	c.mu.Lock()
+	now := time.Now()
+	slot := c.next
+	if slot.Before(now) {
+		slot = now
+	}
+	c.next = slot.Add(c.interval)
+	c.mu.Unlock()
+
+	timer := time.NewTimer(slot.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p Place) coordinates() (*t.Coordinates, error) {
+	lat, err := strconv.ParseFloat(p.Lat, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid latitude '%v' returned from nominatim", p.Lat))
+	}
+	lon, err := strconv.ParseFloat(p.Lon, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid longitude '%v' returned from nominatim", p.Lon))
+	}
+	return &t.Coordinates{
+		Latitude:  lat,
+		Longitude: lon,
+	}, nil
+}
+
+func (p Place) location() *t.Location {
+	a := p.Address
+	return &t.Location{
+		Number:   a.HouseNumber,
+		Street:   a.Road,
+		Locality: firstNonEmpty(a.City, a.Town, a.Village, a.Hamlet, a.Municipality),
+		Region:   firstNonEmpty(a.State, a.Province),
+		Country:  a.Country,
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
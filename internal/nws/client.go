@@ -0,0 +1,282 @@
+package nws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/common"
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+	"github.com/evanhutnik/wipercheck-service/internal/weather"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type PointResponse struct {
+	Properties Point `json:"properties"`
+}
+
+type Point struct {
+	GridId   string `json:"gridId"`
+	GridX    int    `json:"gridX"`
+	GridY    int    `json:"gridY"`
+	TimeZone string `json:"timeZone"`
+}
+
+type ForecastResponse struct {
+	Properties Forecast `json:"properties"`
+}
+
+type Forecast struct {
+	Periods []Period `json:"periods"`
+}
+
+type Period struct {
+	StartTime                  string  `json:"startTime"`
+	Temperature                float64 `json:"temperature"`
+	TemperatureUnit            string  `json:"temperatureUnit"`
+	ProbabilityOfPrecipitation Value   `json:"probabilityOfPrecipitation"`
+	WindSpeed                  string  `json:"windSpeed"`
+	ShortForecast              string  `json:"shortForecast"`
+}
+
+type Value struct {
+	UnitCode string   `json:"unitCode"`
+	Value    *float64 `json:"value"`
+}
+
+const (
+	// maxPoints and maxGrids bound the number of cached points and forecast grids
+	maxPoints = 10000
+	maxGrids  = 1000
+	// gridTTL is how long an hourly forecast is reused for all the points in its grid
+	gridTTL = 30 * time.Minute
+)
+
+type ClientOption func(*Client)
+
+type Client struct {
+	baseUrl   string
+	userAgent string
+
+	mu sync.Mutex
+	// points maps coordinates rounded to ~1 km, well under the ~2.5 km grid spacing, to their grid key
+	points map[string]string
+	// grids keeps the hourly forecast of each office/gridX,gridY
+	grids map[string]*grid
+}
+
+// grid is a forecast office grid cell and its cached hourly forecast
+type grid struct {
+	point   Point
+	periods []Period
+	expires time.Time
+}
+
+func BaseUrlOption(baseUrl string) ClientOption {
+	return func(c *Client) {
+		c.baseUrl = baseUrl
+	}
+}
+
+func UserAgentOption(userAgent string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+func New(opts ...ClientOption) *Client {
+	c := &Client{
+		points: make(map[string]string),
+		grids:  make(map[string]*grid),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.baseUrl == "" {
+		panic("Missing baseUrl in nws client")
+	}
+	// the NWS API rejects requests without a User-Agent identifying the application
+	if c.userAgent == "" {
+		panic("Missing userAgent in nws client")
+	}
+	return c
+}
+
+func (c *Client) GetHourlyWeather(ctx context.Context, coords types.Coordinates, time int64, lang string) (*types.Weather, error) {
+	weatherData, err := c.GetWeather(ctx, coords.Latitude, coords.Longitude, lang)
+	if err != nil {
+		return nil, err
+	}
+	for _, hourly := range weatherData {
+		if hourly.Time == time {
+			return &hourly, nil
+		}
+	}
+	return nil, weather.ErrOutOfRange
+}
+
+func (c *Client) GetWeather(ctx context.Context, lat float64, long float64, lang string) ([]types.Weather, error) {
+	g, err := c.grid(ctx, lat, long)
+	if err != nil {
+		return nil, err
+	}
+	return c.hourlyWeatherFromNWS(g.periods, g.point.TimeZone, lang), nil
+}
+
+// grid resolves the coordinates to their forecast grid and its hourly forecast, fetching either if not cached
+func (c *Client) grid(ctx context.Context, lat float64, long float64) (*grid, error) {
+	pointKey := fmt.Sprintf("%.2f,%.2f", lat, long)
+	c.mu.Lock()
+	gridKey, ok := c.points[pointKey]
+	g := c.grids[gridKey]
+	c.mu.Unlock()
+	if g != nil && time.Now().Before(g.expires) {
+		return g, nil
+	}
+
+	var point Point
+	if ok && g != nil {
+		point = g.point
+	} else {
+		var respObj PointResponse
+		// the points endpoint only accepts up to 4 decimal places
+		if err := c.get(ctx, fmt.Sprintf("%v/points/%.4f,%.4f", c.baseUrl, lat, long), &respObj); err != nil {
+			return nil, err
+		}
+		if respObj.Properties.GridId == "" {
+			return nil, errors.New(fmt.Sprintf("no nws forecast grid found for %v", pointKey))
+		}
+		point = respObj.Properties
+		gridKey = fmt.Sprintf("%v/%d,%d", point.GridId, point.GridX, point.GridY)
+
+		// another point in the same grid may have fetched its forecast already
+		c.mu.Lock()
+		c.putPoint(pointKey, gridKey)
+		g = c.grids[gridKey]
+		c.mu.Unlock()
+		if g != nil && time.Now().Before(g.expires) {
+			return g, nil
+		}
+	}
+
+	reqUrl := fmt.Sprintf("%v/gridpoints/%v/forecast/hourly?units=si", c.baseUrl, gridKey)
+	var respObj ForecastResponse
+	if err := c.get(ctx, reqUrl, &respObj); err != nil {
+		return nil, err
+	}
+	g = &grid{
+		point:   point,
+		periods: respObj.Properties.Periods,
+		expires: time.Now().Add(gridTTL),
+	}
+	c.mu.Lock()
+	c.putGrid(gridKey, g)
+	c.mu.Unlock()
+	return g, nil
+}
+
+// putPoint caches the grid of a point, making room by dropping arbitrary points once full
+func (c *Client) putPoint(pointKey string, gridKey string) {
+	for key := range c.points {
+		if len(c.points) < maxPoints {
+			break
+		}
+		delete(c.points, key)
+	}
+	c.points[pointKey] = gridKey
+}
+
+// putGrid caches the forecast of a grid, dropping expired grids and then arbitrary ones once full
+func (c *Client) putGrid(gridKey string, g *grid) {
+	if len(c.grids) >= maxGrids {
+		now := time.Now()
+		for key, cached := range c.grids {
+			if now.After(cached.expires) {
+				delete(c.grids, key)
+			}
+		}
+		for key := range c.grids {
+			if len(c.grids) < maxGrids {
+				break
+			}
+			delete(c.grids, key)
+		}
+	}
+	c.grids[gridKey] = g
+}
+
+func (c *Client) get(ctx context.Context, reqUrl string, respObj interface{}) error {
+	ctxReq, err := http.NewRequestWithContext(ctx, "GET", reqUrl, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("failed to create nws request %s: %s", reqUrl, err.Error()))
+	}
+	ctxReq.Header.Set("User-Agent", c.userAgent)
+	ctxReq.Header.Set("Accept", "application/geo+json")
+	resp, err := common.GetWithRetry(ctxReq, "nws")
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error reading nws response body: %s", err.Error()))
+	}
+
+	err = json.Unmarshal(body, respObj)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error unmarshalling response from nws: %s", err.Error()))
+	}
+	return nil
+}
+
+func (c *Client) hourlyWeatherFromNWS(periods []Period, timezone string, lang string) []types.Weather {
+	var hourly []types.Weather
+	for _, period := range periods {
+		start, err := time.Parse(time.RFC3339, period.StartTime)
+		if err != nil {
+			continue
+		}
+		w := types.Weather{
+			Time:       start.Unix(),
+			Timezone:   timezone,
+			Temp:       period.Temperature,
+			WindSpeed:  windSpeed(period.WindSpeed),
+			Conditions: conditionsFromForecast(period.ShortForecast, lang),
+		}
+		if period.TemperatureUnit == "F" {
+			w.Temp = (period.Temperature - 32) * 5 / 9
+		}
+		if period.ProbabilityOfPrecipitation.Value != nil {
+			// nws returns a percentage while the service uses a fraction
+			w.Pop = *period.ProbabilityOfPrecipitation.Value / 100
+		}
+		hourly = append(hourly, w)
+	}
+	return hourly
+}
+
+var windPattern = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// windSpeed parses wind speeds such as '15 km/h' or '10 to 20 mph' into km/h, using the upper end of ranges
+func windSpeed(speed string) float64 {
+	var fastest float64
+	for _, match := range windPattern.FindAllString(speed, -1) {
+		value, err := strconv.ParseFloat(match, 64)
+		if err == nil && value > fastest {
+			fastest = value
+		}
+	}
+	if strings.Contains(speed, "mph") {
+		fastest *= 1.609344
+	}
+	return fastest
+}
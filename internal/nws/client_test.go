@@ -0,0 +1,173 @@
+package nws
+
+import (
+	"context"
+	"errors"
+	"github.com/evanhutnik/wipercheck-service/internal/testutil"
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+	"github.com/evanhutnik/wipercheck-service/internal/weather"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const pointBody = `{"properties": {"gridId": "OKX", "gridX": 33, "gridY": 35, "timeZone": "America/New_York"}}`
+
+const forecastBody = `{"properties": {"periods": [
+	{"startTime": "2024-01-15T09:00:00-05:00", "temperature": 50, "temperatureUnit": "F",
+	 "probabilityOfPrecipitation": {"unitCode": "wmoUnit:percent", "value": 40},
+	 "windSpeed": "10 mph", "shortForecast": "Chance Light Rain"},
+	{"startTime": "2024-01-15T10:00:00-05:00", "temperature": -2, "temperatureUnit": "C",
+	 "probabilityOfPrecipitation": {"unitCode": "wmoUnit:percent", "value": null},
+	 "windSpeed": "15 to 25 km/h", "shortForecast": "Mostly Cloudy"}
+]}}`
+
+// nwsServer stubs the points and hourly forecast endpoints, counting requests to each
+func nwsServer(t *testing.T, points *int32, forecasts *int32) *httptest.Server {
+	t.Helper()
+	return testutil.Server(t, func(r *http.Request, _ http.Header) (int, []byte) {
+		if r.Header.Get("User-Agent") != "wipercheck-test" {
+			t.Errorf("missing User-Agent header, got %q", r.Header.Get("User-Agent"))
+		}
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/points/"):
+			atomic.AddInt32(points, 1)
+			return http.StatusOK, []byte(pointBody)
+		case r.URL.Path == "/gridpoints/OKX/33,35/forecast/hourly":
+			atomic.AddInt32(forecasts, 1)
+			if r.URL.Query().Get("units") != "si" {
+				t.Errorf("expected units=si, got %q", r.URL.RawQuery)
+			}
+			return http.StatusOK, []byte(forecastBody)
+		default:
+			t.Errorf("unexpected request %v", r.URL)
+			return http.StatusNotFound, nil
+		}
+	})
+}
+
+func TestGetWeather(t *testing.T) {
+	var points, forecasts int32
+	server := nwsServer(t, &points, &forecasts)
+	c := New(BaseUrlOption(server.URL), UserAgentOption("wipercheck-test"))
+
+	hourly, err := c.GetWeather(context.Background(), 40.7128, -74.0060, "en")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hourly) != 2 {
+		t.Fatalf("expected 2 hours, got %d", len(hourly))
+	}
+
+	first := hourly[0]
+	if first.Time != time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("unexpected time %v", time.Unix(first.Time, 0).UTC())
+	}
+	if first.Timezone != "America/New_York" {
+		t.Errorf("expected the point's timezone, got %q", first.Timezone)
+	}
+	// 50 °F
+	if math.Abs(first.Temp-10) > 1e-9 {
+		t.Errorf("expected 10 °C, got %v", first.Temp)
+	}
+	// 10 mph
+	if math.Abs(first.WindSpeed-16.09344) > 1e-9 {
+		t.Errorf("expected 16.09 km/h, got %v", first.WindSpeed)
+	}
+	if first.Pop != 0.4 {
+		t.Errorf("expected a 0.4 chance of precipitation, got %v", first.Pop)
+	}
+	if first.Conditions.Id != 500 {
+		t.Errorf("expected light rain, got %+v", first.Conditions)
+	}
+
+	second := hourly[1]
+	if second.Temp != -2 {
+		t.Errorf("expected °C to be kept, got %v", second.Temp)
+	}
+	if second.WindSpeed != 25 {
+		t.Errorf("expected the upper end of the range, got %v", second.WindSpeed)
+	}
+	if second.Pop != 0 {
+		t.Errorf("expected no chance of precipitation without a value, got %v", second.Pop)
+	}
+	if second.Conditions.Id != 803 {
+		t.Errorf("expected mostly cloudy, got %+v", second.Conditions)
+	}
+
+	if points != 1 || forecasts != 1 {
+		t.Errorf("expected 1 points and 1 forecast request, got %d and %d", points, forecasts)
+	}
+}
+
+func TestGetWeatherCachesGrids(t *testing.T) {
+	var points, forecasts int32
+	server := nwsServer(t, &points, &forecasts)
+	c := New(BaseUrlOption(server.URL), UserAgentOption("wipercheck-test"))
+	ctx := context.Background()
+
+	// the same point again, a point rounding to it and another point in the same grid
+	for _, coords := range [][2]float64{{40.7128, -74.0060}, {40.7128, -74.0060}, {40.7131, -74.0058}, {40.7301, -74.0212}} {
+		if _, err := c.GetWeather(ctx, coords[0], coords[1], "en"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if points != 2 {
+		t.Errorf("expected 2 points requests, got %d", points)
+	}
+	if forecasts != 1 {
+		t.Errorf("expected the forecast to be requested once per grid, got %d", forecasts)
+	}
+
+	// expired forecasts are requested again without resolving the point again
+	for _, g := range c.grids {
+		g.expires = time.Now().Add(-time.Second)
+	}
+	if _, err := c.GetWeather(ctx, 40.7128, -74.0060, "en"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if points != 2 || forecasts != 2 {
+		t.Errorf("expected 2 points and 2 forecast requests, got %d and %d", points, forecasts)
+	}
+}
+
+func TestGetHourlyWeather(t *testing.T) {
+	var points, forecasts int32
+	server := nwsServer(t, &points, &forecasts)
+	c := New(BaseUrlOption(server.URL), UserAgentOption("wipercheck-test"))
+	ctx := context.Background()
+	hour := time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC).Unix()
+
+	w, err := c.GetHourlyWeather(ctx, types.Coordinates{Latitude: 40.7128, Longitude: -74.0060}, hour, "en")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Time != hour || w.Temp != -2 {
+		t.Errorf("expected the 10 AM forecast, got %+v", w)
+	}
+
+	if _, err = c.GetHourlyWeather(ctx, types.Coordinates{Latitude: 40.7128, Longitude: -74.0060}, hour+3600, "en"); !errors.Is(err, weather.ErrOutOfRange) {
+		t.Errorf("expected an out of range error for an hour outside the forecast, got %v", err)
+	}
+}
+
+func TestWindSpeed(t *testing.T) {
+	tests := []struct {
+		speed string
+		want  float64
+	}{
+		{"15 km/h", 15},
+		{"10 mph", 16.09344},
+		{"10 to 20 mph", 32.18688},
+		{"", 0},
+	}
+	for _, test := range tests {
+		if got := windSpeed(test.speed); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("windSpeed(%q) = %v, want %v", test.speed, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package nws
+
+import (
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+	"github.com/evanhutnik/wipercheck-service/internal/weather"
+	"strings"
+)
+
+// conditionsFromForecast maps an NWS short forecast phrase such as 'Chance Light Rain' into conditions
+func conditionsFromForecast(forecast string, lang string) types.Conditions {
+	f := strings.ToLower(forecast)
+	var id int
+	switch {
+	case strings.Contains(f, "thunderstorm"):
+		id = 211
+	case strings.Contains(f, "freezing"):
+		id = 511
+	case strings.Contains(f, "sleet"):
+		id = 611
+	case strings.Contains(f, "rain and snow"), strings.Contains(f, "wintry mix"):
+		id = 616
+	case strings.Contains(f, "snow"):
+		id = intensity(f, 600, 601, 602)
+		if strings.Contains(f, "showers") {
+			id = intensity(f, 620, 620, 622)
+		}
+	case strings.Contains(f, "drizzle"):
+		id = intensity(f, 300, 301, 302)
+	case strings.Contains(f, "showers"):
+		id = intensity(f, 520, 521, 522)
+	case strings.Contains(f, "rain"):
+		id = intensity(f, 500, 501, 502)
+	case strings.Contains(f, "fog"):
+		id = 741
+	case strings.Contains(f, "haze"), strings.Contains(f, "smoke"), strings.Contains(f, "mist"):
+		id = 701
+	case strings.Contains(f, "partly"):
+		id = 802
+	case strings.Contains(f, "mostly cloudy"):
+		id = 803
+	case strings.Contains(f, "mostly"):
+		id = 801
+	case strings.Contains(f, "cloudy"), strings.Contains(f, "overcast"):
+		id = 804
+	case strings.Contains(f, "sunny"), strings.Contains(f, "clear"):
+		id = 800
+	default:
+		return types.Conditions{Description: forecast}
+	}
+	return weather.Conditions(id, lang)
+}
+
+// intensity picks the condition id matching the intensity described in the forecast
+func intensity(forecast string, light int, moderate int, heavy int) int {
+	switch {
+	case strings.Contains(forecast, "light"):
+		return light
+	case strings.Contains(forecast, "heavy"):
+		return heavy
+	default:
+		return moderate
+	}
+}
@@ -0,0 +1,138 @@
+package openmeteo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/common"
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+	"github.com/evanhutnik/wipercheck-service/internal/weather"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type Response struct {
+	Latitude  float64
+	Longitude float64
+	Timezone  string
+	Hourly    Hourly
+}
+
+type Hourly struct {
+	Time                     []int64   `json:"time"`
+	Temperature              []float64 `json:"temperature_2m"`
+	PrecipitationProbability []float64 `json:"precipitation_probability"`
+	WeatherCode              []int     `json:"weather_code"`
+	WindSpeed                []float64 `json:"wind_speed_10m"`
+	WindGusts                []float64 `json:"wind_gusts_10m"`
+}
+
+type ClientOption func(*Client)
+
+type Client struct {
+	baseUrl string
+}
+
+func BaseUrlOption(baseUrl string) ClientOption {
+	return func(c *Client) {
+		c.baseUrl = baseUrl
+	}
+}
+
+func New(opts ...ClientOption) *Client {
+	c := &Client{}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.baseUrl == "" {
+		panic("Missing baseUrl in openmeteo client")
+	}
+	return c
+}
+
+func (c Client) GetHourlyWeather(ctx context.Context, coords types.Coordinates, time int64, lang string) (*types.Weather, error) {
+	weatherData, err := c.GetWeather(ctx, coords.Latitude, coords.Longitude, lang)
+	if err != nil {
+		return nil, err
+	}
+	for _, hourly := range weatherData {
+		if hourly.Time == time {
+			return &hourly, nil
+		}
+	}
+	return nil, weather.ErrOutOfRange
+}
+
+func (c Client) GetWeather(ctx context.Context, lat float64, long float64, lang string) ([]types.Weather, error) {
+	req, err := url.Parse(c.baseUrl)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("failed to parse baseUrl %s: %s", c.baseUrl, err.Error()))
+		return nil, err
+	}
+
+	q := req.Query()
+	q.Add("latitude", strconv.FormatFloat(lat, 'f', -1, 64))
+	q.Add("longitude", strconv.FormatFloat(long, 'f', -1, 64))
+	q.Add("hourly", "temperature_2m,precipitation_probability,weather_code,wind_speed_10m,wind_gusts_10m")
+	q.Add("timeformat", "unixtime")
+	q.Add("timezone", "auto")
+	q.Add("forecast_days", "3")
+	req.RawQuery = q.Encode()
+
+	ctxReq, _ := http.NewRequestWithContext(ctx, "GET", req.String(), nil)
+	resp, err := common.GetWithRetry(ctxReq, "openmeteo")
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error reading body of response: %s", err.Error()))
+		return nil, err
+	}
+
+	var respObj Response
+	err = json.Unmarshal(body, &respObj)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error unmarshalling response from openmeteo: %s", err.Error()))
+		return nil, err
+	}
+
+	return c.hourlyWeatherFromOM(respObj, lang), nil
+}
+
+func (c Client) hourlyWeatherFromOM(resp Response, lang string) []types.Weather {
+	hourly := resp.Hourly
+	var weather []types.Weather
+	// hourly values are returned as parallel arrays indexed by time
+	for i, time := range hourly.Time {
+		w := types.Weather{
+			Time:     time,
+			Timezone: resp.Timezone,
+		}
+		if i < len(hourly.PrecipitationProbability) {
+			// open-meteo returns a percentage while the service uses a fraction
+			w.Pop = hourly.PrecipitationProbability[i] / 100
+		}
+		if i < len(hourly.Temperature) {
+			w.Temp = hourly.Temperature[i]
+		}
+		if i < len(hourly.WindSpeed) {
+			w.WindSpeed = hourly.WindSpeed[i]
+		}
+		if i < len(hourly.WindGusts) {
+			w.WindGust = hourly.WindGusts[i]
+		}
+		if i < len(hourly.WeatherCode) {
+			w.Conditions = conditionsFromWMO(hourly.WeatherCode[i], lang)
+		}
+		weather = append(weather, w)
+	}
+	return weather
+}
@@ -0,0 +1,48 @@
+package openmeteo
+
+import (
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+	"github.com/evanhutnik/wipercheck-service/internal/weather"
+)
+
+// wmoConditions maps WMO weather interpretation codes to OpenWeather condition ids
+// https://open-meteo.com/en/docs#weathervariables
+var wmoConditions = map[int]int{
+	0:  800, // clear sky
+	1:  801, // mainly clear
+	2:  802, // partly cloudy
+	3:  804, // overcast
+	45: 741, // fog
+	48: 741, // depositing rime fog
+	51: 300, // light drizzle
+	53: 301, // moderate drizzle
+	55: 302, // dense drizzle
+	56: 511, // light freezing drizzle
+	57: 511, // dense freezing drizzle
+	61: 500, // slight rain
+	63: 501, // moderate rain
+	65: 502, // heavy rain
+	66: 511, // light freezing rain
+	67: 511, // heavy freezing rain
+	71: 600, // slight snow fall
+	73: 601, // moderate snow fall
+	75: 602, // heavy snow fall
+	77: 600, // snow grains
+	80: 520, // slight rain showers
+	81: 521, // moderate rain showers
+	82: 522, // violent rain showers
+	85: 620, // slight snow showers
+	86: 622, // heavy snow showers
+	95: 211, // thunderstorm
+	96: 201, // thunderstorm with slight hail
+	99: 202, // thunderstorm with heavy hail
+}
+
+// conditionsFromWMO maps a WMO weather code into conditions
+func conditionsFromWMO(code int, lang string) types.Conditions {
+	id, ok := wmoConditions[code]
+	if !ok {
+		return types.Conditions{}
+	}
+	return weather.Conditions(id, lang)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/evanhutnik/wipercheck-service/internal/common"
 	"github.com/evanhutnik/wipercheck-service/internal/types"
+	"github.com/evanhutnik/wipercheck-service/internal/weather"
 	"io"
 	"net/http"
 	"net/url"
@@ -14,14 +15,18 @@ import (
 )
 
 type Response struct {
-	Lat    float64
-	Lon    float64
-	Hourly []HourlyWeather
+	Lat      float64
+	Lon      float64
+	Timezone string
+	Hourly   []HourlyWeather
 }
 
 type HourlyWeather struct {
 	Time       int64 `json:"dt"`
 	Pop        float64
+	Temp       float64
+	WindSpeed  float64      `json:"wind_speed"`
+	WindGust   float64      `json:"wind_gust"`
 	Conditions []Conditions `json:"weather"`
 }
 
@@ -67,8 +72,8 @@ func New(opts ...ClientOption) *Client {
 	return c
 }
 
-func (c Client) GetHourlyWeather(ctx context.Context, coords types.Coordinates, time int64) (*types.Weather, error) {
-	weatherData, err := c.GetWeather(ctx, coords.Latitude, coords.Longitude)
+func (c Client) GetHourlyWeather(ctx context.Context, coords types.Coordinates, time int64, lang string) (*types.Weather, error) {
+	weatherData, err := c.GetWeather(ctx, coords.Latitude, coords.Longitude, lang)
 	if err != nil {
 		return nil, err
 	}
@@ -77,10 +82,10 @@ func (c Client) GetHourlyWeather(ctx context.Context, coords types.Coordinates,
 			return &hourly, nil
 		}
 	}
-	return nil, errors.New("no hourly weather found for time")
+	return nil, weather.ErrOutOfRange
 }
 
-func (c Client) GetWeather(ctx context.Context, lat float64, long float64) ([]types.Weather, error) {
+func (c Client) GetWeather(ctx context.Context, lat float64, long float64, lang string) ([]types.Weather, error) {
 	req, err := url.Parse(c.baseUrl)
 	if err != nil {
 		err = errors.New(fmt.Sprintf("failed to parse baseUrl %s: %s", c.baseUrl, err.Error()))
@@ -93,6 +98,9 @@ func (c Client) GetWeather(ctx context.Context, lat float64, long float64) ([]ty
 	q.Add("lon", strconv.FormatFloat(long, 'f', -1, 64))
 	q.Add("units", "metric")
 	q.Add("exclude", "current,minutely,daily,alerts")
+	if lang != "" {
+		q.Add("lang", lang)
+	}
 	req.RawQuery = q.Encode()
 
 	ctxReq, _ := http.NewRequestWithContext(ctx, "GET", req.String(), nil)
@@ -115,10 +123,10 @@ func (c Client) GetWeather(ctx context.Context, lat float64, long float64) ([]ty
 		return nil, err
 	}
 
-	return c.hourlyWeatherFromOW(respObj.Hourly), nil
+	return c.hourlyWeatherFromOW(respObj.Hourly, respObj.Timezone), nil
 }
 
-func (c Client) hourlyWeatherFromOW(owHourly []HourlyWeather) []types.Weather {
+func (c Client) hourlyWeatherFromOW(owHourly []HourlyWeather, timezone string) []types.Weather {
 	var hourly []types.Weather
 	for _, owHour := range owHourly {
 		var conditions types.Conditions
@@ -132,8 +140,13 @@ func (c Client) hourlyWeatherFromOW(owHourly []HourlyWeather) []types.Weather {
 		}
 		hourly = append(hourly, types.Weather{
 			Time:       owHour.Time,
+			Timezone:   timezone,
 			Conditions: conditions,
 			Pop:        owHour.Pop,
+			Temp:       owHour.Temp,
+			// converting wind from m/s to km/h
+			WindSpeed: owHour.WindSpeed * 3.6,
+			WindGust:  owHour.WindGust * 3.6,
 		})
 	}
 	return hourly
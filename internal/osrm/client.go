@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"github.com/evanhutnik/wipercheck-service/internal/common"
+	"github.com/evanhutnik/wipercheck-service/internal/routing"
 	t "github.com/evanhutnik/wipercheck-service/internal/types"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 )
 
 type Response struct {
@@ -17,12 +20,19 @@ type Response struct {
 	Routes []Route `json:"routes"`
 }
 
+type TableResponse struct {
+	Code      string       `json:"code"`
+	Durations [][]*float64 `json:"durations"`
+	Distances [][]*float64 `json:"distances"`
+}
+
 type Route struct {
-	WeightName string  `json:"weight_name"`
-	Weight     float64 `json:"weight"`
-	Duration   float64 `json:"duration"`
-	Distance   float64 `json:"distance"`
-	Legs       []Leg   `json:"legs"`
+	Geometry   Geometry `json:"geometry"`
+	WeightName string   `json:"weight_name"`
+	Weight     float64  `json:"weight"`
+	Duration   float64  `json:"duration"`
+	Distance   float64  `json:"distance"`
+	Legs       []Leg    `json:"legs"`
 }
 
 type Leg struct {
@@ -34,7 +44,7 @@ type Leg struct {
 }
 
 type Step struct {
-	Geometry     string   `json:"geometry"`
+	Geometry     Geometry `json:"geometry"`
 	Mode         string   `json:"mode"`
 	DrivingSide  string   `json:"driving_side"`
 	Name         string   `json:"name"`
@@ -46,6 +56,12 @@ type Step struct {
 	Maneuver     Maneuver `json:"maneuver"`
 }
 
+// Geometry is a GeoJSON LineString, with coordinates as longitude,latitude pairs
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates [][]float64 `json:"coordinates"`
+}
+
 type Maneuver struct {
 	BearingAfter  int       `json:"bearing_after"`
 	BearingBefore int       `json:"bearing_before"`
@@ -58,29 +74,65 @@ type Maneuver struct {
 type ClientOption func(*Client)
 
 type Client struct {
-	baseUrl string
+	// baseUrls are keyed by vehicle, as an OSRM server's profile is part of its url
+	baseUrls map[string]string
 }
 
+// BaseUrlOption sets the url of the car profile, e.g. http://router.project-osrm.org/route/v1/driving
 func BaseUrlOption(baseUrl string) ClientOption {
+	return ProfileUrlOption(routing.VehicleCar, baseUrl)
+}
+
+// ProfileUrlOption sets the url of the profile used to route the vehicle
+func ProfileUrlOption(vehicle string, baseUrl string) ClientOption {
 	return func(c *Client) {
-		c.baseUrl = baseUrl
+		if baseUrl != "" {
+			c.baseUrls[vehicle] = baseUrl
+		}
 	}
 }
 
 func New(opts ...ClientOption) *Client {
-	c := &Client{}
+	c := &Client{
+		baseUrls: make(map[string]string),
+	}
 	for _, opt := range opts {
 		opt(c)
 	}
 
-	if c.baseUrl == "" {
+	if c.baseUrls[routing.VehicleCar] == "" {
 		panic("Missing baseUrl in osrm client")
 	}
 	return c
 }
 
-func (c *Client) Route(ctx context.Context, trip *t.Trip) (*t.Route, error) {
-	reqUrl := fmt.Sprintf("%v/%f,%f;%f,%f", c.baseUrl, trip.From.Longitude, trip.From.Latitude, trip.To.Longitude, trip.To.Latitude)
+// Supports returns whether a profile url was configured for the vehicle
+func (c *Client) Supports(vehicle string) bool {
+	_, ok := c.baseUrls[vehicle]
+	return ok
+}
+
+// SupportsAvoid assumes the profiles of motorized vehicles are based on OSRM's default car profile, which can exclude
+// tolls, motorways or ferries one at a time, and that the other profiles can't exclude anything
+func (c *Client) SupportsAvoid(vehicle string, avoid []string) bool {
+	return len(avoid) == 0 || (routing.Motorized(vehicle) && len(avoid) == 1)
+}
+
+func (c *Client) Route(ctx context.Context, trip *t.Trip, opts routing.Options) (*t.Route, error) {
+	vehicle := opts.Vehicle
+	if vehicle == "" {
+		vehicle = routing.VehicleCar
+	}
+	baseUrl, ok := c.baseUrls[vehicle]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("no osrm profile configured for vehicle %v", vehicle))
+	}
+	coords := []string{fmt.Sprintf("%f,%f", trip.From.Longitude, trip.From.Latitude)}
+	for _, via := range opts.Via {
+		coords = append(coords, fmt.Sprintf("%f,%f", via.Longitude, via.Latitude))
+	}
+	coords = append(coords, fmt.Sprintf("%f,%f", trip.To.Longitude, trip.To.Latitude))
+	reqUrl := fmt.Sprintf("%v/%v", baseUrl, strings.Join(coords, ";"))
 	req, err := url.Parse(reqUrl)
 	if err != nil {
 		err = errors.New(fmt.Sprintf("failed to parse osrm url %s: %s", reqUrl, err.Error()))
@@ -89,7 +141,11 @@ func (c *Client) Route(ctx context.Context, trip *t.Trip) (*t.Route, error) {
 
 	q := req.Query()
 	q.Add("steps", "true")
-	q.Add("overview", "false")
+	q.Add("overview", "full")
+	q.Add("geometries", "geojson")
+	if exclude := excludeClasses(opts); exclude != "" {
+		q.Add("exclude", exclude)
+	}
 	req.RawQuery = q.Encode()
 
 	ctxReq, _ := http.NewRequestWithContext(ctx, "GET", req.String(), nil)
@@ -98,6 +154,7 @@ func (c *Client) Route(ctx context.Context, trip *t.Trip) (*t.Route, error) {
 		return nil, err
 	}
 
+	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		err = errors.New(fmt.Sprintf("error reading osrm response body: %s", err.Error()))
@@ -109,21 +166,57 @@ func (c *Client) Route(ctx context.Context, trip *t.Trip) (*t.Route, error) {
 	if err != nil {
 		err = errors.New(fmt.Sprintf("error unmarshalling response from osrm: %s", err.Error()))
 		return nil, err
+	} else if len(respObj.Routes) == 0 || len(respObj.Routes[0].Legs) == 0 {
+		err = errors.New(fmt.Sprintf("no route returned from osrm, code %v", respObj.Code))
+		return nil, err
 	}
 
+	var geometry []t.Coordinates
+	for _, coords := range respObj.Routes[0].Geometry.Coordinates {
+		geometry = append(geometry, t.Coordinates{Latitude: coords[1], Longitude: coords[0]})
+	}
+	// via points split the route into legs, which are joined back together
+	var steps []t.Step
+	for _, leg := range respObj.Routes[0].Legs {
+		steps = append(steps, c.routeStepsFromOSRM(leg.Steps)...)
+	}
 	route := &t.Route{
-		Steps:    c.routeStepsFromOSRM(respObj.Routes[0].Legs[0].Steps),
+		Steps:    steps,
+		Geometry: geometry,
 		Duration: respObj.Routes[0].Duration,
+		Distance: respObj.Routes[0].Distance,
 	}
 	return route, nil
 }
 
+// excludeClasses returns the comma-separated road classes to exclude from the route.
+// These are the classes defined by OSRM's car profile
+func excludeClasses(opts routing.Options) string {
+	var classes []string
+	if opts.Avoids(routing.AvoidTolls) {
+		classes = append(classes, "toll")
+	}
+	if opts.Avoids(routing.AvoidMotorways) {
+		classes = append(classes, "motorway")
+	}
+	if opts.Avoids(routing.AvoidFerries) {
+		classes = append(classes, "ferry")
+	}
+	return strings.Join(classes, ",")
+}
+
 func (c Client) routeStepsFromOSRM(osrm []Step) []t.Step {
 	var routeSteps []t.Step
 	for _, step := range osrm {
+		var mode string
+		if step.Mode == "ferry" {
+			mode = routing.ModeFerry
+		}
 		routeSteps = append(routeSteps, t.Step{
 			Name:         step.Name,
+			Mode:         mode,
 			StepDuration: step.Duration,
+			StepDistance: step.Distance,
 			Coordinates: t.Coordinates{
 				Latitude:  step.Maneuver.Location[1],
 				Longitude: step.Maneuver.Location[0],
@@ -132,3 +225,87 @@ func (c Client) routeStepsFromOSRM(osrm []Step) []t.Step {
 	}
 	return routeSteps
 }
+
+// Table finds the durations and distances between the origins and destinations with OSRM's table service,
+// whose url is the profile's url with the route service replaced, e.g. http://router.project-osrm.org/table/v1/driving
+func (c *Client) Table(ctx context.Context, origins []t.Coordinates, destinations []t.Coordinates, opts routing.Options) (*routing.Table, error) {
+	vehicle := opts.Vehicle
+	if vehicle == "" {
+		vehicle = routing.VehicleCar
+	}
+	baseUrl, ok := c.baseUrls[vehicle]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("no osrm profile configured for vehicle %v", vehicle))
+	} else if !strings.Contains(baseUrl, "/route/") {
+		return nil, errors.New(fmt.Sprintf("osrm url %v is not for the route service", baseUrl))
+	}
+
+	var coords, sources, destIndexes []string
+	for i, origin := range origins {
+		coords = append(coords, fmt.Sprintf("%f,%f", origin.Longitude, origin.Latitude))
+		sources = append(sources, strconv.Itoa(i))
+	}
+	for i, destination := range destinations {
+		coords = append(coords, fmt.Sprintf("%f,%f", destination.Longitude, destination.Latitude))
+		destIndexes = append(destIndexes, strconv.Itoa(len(origins)+i))
+	}
+	reqUrl := fmt.Sprintf("%v/%v", strings.Replace(baseUrl, "/route/", "/table/", 1), strings.Join(coords, ";"))
+	req, err := url.Parse(reqUrl)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("failed to parse osrm url %s: %s", reqUrl, err.Error()))
+		return nil, err
+	}
+
+	q := req.Query()
+	q.Add("sources", strings.Join(sources, ";"))
+	q.Add("destinations", strings.Join(destIndexes, ";"))
+	q.Add("annotations", "duration,distance")
+	if exclude := excludeClasses(opts); exclude != "" {
+		q.Add("exclude", exclude)
+	}
+	req.RawQuery = q.Encode()
+
+	ctxReq, _ := http.NewRequestWithContext(ctx, "GET", req.String(), nil)
+	resp, err := common.GetWithRetry(ctxReq, "osrm")
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error reading osrm response body: %s", err.Error()))
+		return nil, err
+	}
+
+	var respObj TableResponse
+	err = json.Unmarshal(body, &respObj)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error unmarshalling response from osrm: %s", err.Error()))
+		return nil, err
+	} else if len(respObj.Durations) == 0 {
+		err = errors.New(fmt.Sprintf("no table returned from osrm, code %v", respObj.Code))
+		return nil, err
+	} else if !tableShape(respObj.Durations, len(origins), len(destinations)) ||
+		!tableShape(respObj.Distances, len(origins), len(destinations)) {
+		err = errors.New(fmt.Sprintf("osrm table doesn't match the %d origins and %d destinations", len(origins), len(destinations)))
+		return nil, err
+	}
+	return &routing.Table{
+		Durations: respObj.Durations,
+		Distances: respObj.Distances,
+	}, nil
+}
+
+// tableShape returns whether the table has a row for each origin with a column for each destination
+func tableShape(table [][]*float64, origins int, destinations int) bool {
+	if len(table) != origins {
+		return false
+	}
+	for _, row := range table {
+		if len(row) != destinations {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package places
+
+import (
+	"encoding/json"
+	"fmt"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"os"
+	"strings"
+)
+
+// Registry resolves user-defined place names such as 'depot-3' to coordinates
+type Registry struct {
+	places map[string]t.Coordinates
+}
+
+// Load reads a registry from a JSON file mapping place names to coordinates, e.g.
+// {"depot-3": {"latitude": 42.33, "longitude": -83.04}}
+func Load(file string) (*Registry, error) {
+	body, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var places map[string]t.Coordinates
+	if err = json.Unmarshal(body, &places); err != nil {
+		return nil, fmt.Errorf("error unmarshalling places file: %s", err.Error())
+	}
+
+	r := &Registry{places: make(map[string]t.Coordinates)}
+	for name, coords := range places {
+		r.places[normalize(name)] = coords
+	}
+	return r, nil
+}
+
+// Lookup returns the coordinates of the named place, ignoring case
+func (r *Registry) Lookup(name string) (*t.Coordinates, bool) {
+	if r == nil {
+		return nil, false
+	}
+	coords, ok := r.places[normalize(name)]
+	if !ok {
+		return nil, false
+	}
+	return &coords, true
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,175 @@
+// Package pluscode decodes Open Location Codes (plus codes) locally
+// https://github.com/google/open-location-code/blob/main/docs/specification.md
+package pluscode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	alphabet       = "23456789CFGHJMPQRVWX"
+	separator      = '+'
+	separatorIndex = 8
+	padding        = '0'
+	// pairCodeLength is the number of digits encoded as alternating latitude and longitude pairs
+	pairCodeLength = 10
+	gridRows       = 5
+	gridColumns    = 4
+)
+
+// pairResolutions are the sizes in degrees of each pair of digits
+var pairResolutions = []float64{20, 1, 0.05, 0.0025, 0.000125}
+
+// CodeArea is the area described by a plus code
+type CodeArea struct {
+	LatitudeLo  float64
+	LongitudeLo float64
+	LatitudeHi  float64
+	LongitudeHi float64
+}
+
+// Center returns the center of the code area
+func (a CodeArea) Center() (float64, float64) {
+	return (a.LatitudeLo + a.LatitudeHi) / 2, (a.LongitudeLo + a.LongitudeHi) / 2
+}
+
+// IsValid returns whether the string is a syntactically valid full or short plus code
+func IsValid(code string) bool {
+	code = strings.ToUpper(code)
+	sep := strings.IndexRune(code, separator)
+	if sep < 0 || sep != strings.LastIndex(code, string(separator)) || sep > separatorIndex || sep%2 == 1 {
+		return false
+	}
+	// a single character after the separator is not valid
+	if len(code)-sep-1 == 1 {
+		return false
+	}
+	pad := strings.IndexRune(code, padding)
+	if pad >= 0 {
+		// padding is only allowed in full codes, before the separator, in pairs and with nothing after the separator
+		if sep < separatorIndex || pad == 0 || pad%2 == 1 || sep != len(code)-1 ||
+			strings.Trim(code[pad:sep], string(padding)) != "" {
+			return false
+		}
+	}
+	for i, r := range code {
+		if i == sep || (pad >= 0 && i >= pad && i < sep) {
+			continue
+		}
+		if !strings.ContainsRune(alphabet, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsShort returns whether the code is a valid short code, which needs a reference location to be decoded
+func IsShort(code string) bool {
+	return IsValid(code) && strings.IndexRune(code, separator) < separatorIndex
+}
+
+// IsFull returns whether the code is a valid full code
+func IsFull(code string) bool {
+	if !IsValid(code) || IsShort(code) {
+		return false
+	}
+	code = strings.ToUpper(code)
+	// the first latitude digit can't exceed 180 degrees and the first longitude digit 360 degrees
+	return strings.IndexByte(alphabet, code[0])*20 < 180 && strings.IndexByte(alphabet, code[1])*20 < 360
+}
+
+// Decode returns the area described by a full plus code
+func Decode(code string) (CodeArea, error) {
+	if !IsFull(code) {
+		return CodeArea{}, errors.New(fmt.Sprintf("'%v' is not a full plus code", code))
+	}
+	digits := strings.ToUpper(strings.ReplaceAll(code, string(separator), ""))
+	digits = strings.TrimRight(digits, string(padding))
+
+	lat, lng := -90.0, -180.0
+	var latRes, lngRes float64
+	for i := 0; i < len(digits) && i < pairCodeLength; i += 2 {
+		latRes = pairResolutions[i/2]
+		lngRes = pairResolutions[i/2]
+		lat += float64(strings.IndexByte(alphabet, digits[i])) * latRes
+		if i+1 < len(digits) {
+			lng += float64(strings.IndexByte(alphabet, digits[i+1])) * lngRes
+		}
+	}
+	// digits after the pairs refine a grid of rows and columns
+	for i := pairCodeLength; i < len(digits); i++ {
+		latRes /= gridRows
+		lngRes /= gridColumns
+		value := strings.IndexByte(alphabet, digits[i])
+		lat += float64(value/gridColumns) * latRes
+		lng += float64(value%gridColumns) * lngRes
+	}
+	return CodeArea{
+		LatitudeLo:  lat,
+		LongitudeLo: lng,
+		LatitudeHi:  lat + latRes,
+		LongitudeHi: lng + lngRes,
+	}, nil
+}
+
+// RecoverNearest returns the full code nearest to the reference location matching the short code
+func RecoverNearest(code string, refLat float64, refLng float64) (string, error) {
+	if IsFull(code) {
+		return strings.ToUpper(code), nil
+	}
+	if !IsShort(code) {
+		return "", errors.New(fmt.Sprintf("'%v' is not a valid plus code", code))
+	}
+	code = strings.ToUpper(code)
+	paddingLength := separatorIndex - strings.IndexRune(code, separator)
+	// the resolution of the digits missing from the short code
+	resolution := math.Pow(20, 2-float64(paddingLength)/2)
+	halfResolution := resolution / 2
+
+	full := encode(refLat, refLng)[:paddingLength] + code
+	area, err := Decode(full)
+	if err != nil {
+		return "", err
+	}
+	lat, lng := area.Center()
+	// the nearest match may be in the neighbouring cell of the reference location
+	if refLat+halfResolution < lat && lat-resolution >= -90 {
+		lat -= resolution
+	} else if refLat-halfResolution > lat && lat+resolution <= 90 {
+		lat += resolution
+	}
+	if refLng+halfResolution < lng {
+		lng -= resolution
+	} else if refLng-halfResolution > lng {
+		lng += resolution
+	}
+	return encode(lat, lng)[:paddingLength] + code, nil
+}
+
+// encode returns the 10 digit plus code of the location, with the separator
+func encode(lat float64, lng float64) string {
+	lat = math.Min(math.Max(lat, -90), 90)
+	// latitude 90 is encoded as the top of the last cell
+	if lat == 90 {
+		lat -= pairResolutions[len(pairResolutions)-1] / 2
+	}
+	lng = math.Mod(math.Mod(lng+180, 360)+360, 360)
+	lat += 90
+
+	var builder strings.Builder
+	for i, res := range pairResolutions {
+		latDigit := int(math.Floor(lat / res))
+		lngDigit := int(math.Floor(lng / res))
+		lat -= float64(latDigit) * res
+		lng -= float64(lngDigit) * res
+		builder.WriteByte(alphabet[latDigit])
+		builder.WriteByte(alphabet[lngDigit])
+		if i == 3 {
+			builder.WriteRune(separator)
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,86 @@
+package pluscode
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		code string
+		lat  float64
+		lng  float64
+	}{
+		{"8FVC9G8F+6X", 47.365563, 8.524938},
+		{"8fvc9g8f+6x", 47.365563, 8.524938},
+		// grid digits after the pairs
+		{"8FVC9G8F+6XR", 47.365613, 8.524891},
+		// padded codes describe larger areas
+		{"8FVC0000+", 47.5, 8.5},
+	}
+	for _, test := range tests {
+		area, err := Decode(test.code)
+		if err != nil {
+			t.Errorf("Decode(%q): unexpected error: %v", test.code, err)
+			continue
+		}
+		lat, lng := area.Center()
+		if math.Abs(lat-test.lat) > 1e-6 || math.Abs(lng-test.lng) > 1e-6 {
+			t.Errorf("Decode(%q) center = %v,%v, want %v,%v", test.code, lat, lng, test.lat, test.lng)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, code := range []string{
+		"",
+		// no separator, or more than one
+		"8FVC9G8F6X",
+		"8FVC9G8F++6X",
+		// separator at an odd position
+		"8FVC9G8+6X",
+		// a single digit after the separator
+		"8FVC9G8F+6",
+		// a character outside the alphabet
+		"8FVC9G8A+6X",
+		// padding followed by digits
+		"8FVC0000+6X",
+		// first latitude digit past 90 degrees north
+		"WFVC9G8F+6X",
+		// short codes need a reference location
+		"9G8F+6X",
+	} {
+		if _, err := Decode(code); err == nil {
+			t.Errorf("Decode(%q): expected an error", code)
+		}
+	}
+}
+
+func TestRecoverNearest(t *testing.T) {
+	tests := []struct {
+		code   string
+		refLat float64
+		refLng float64
+		want   string
+	}{
+		{"9G8F+6X", 47.4, 8.6, "8FVC9G8F+6X"},
+		{"8F+6X", 47.365, 8.525, "8FVC9G8F+6X"},
+		{"9g8f+6x", 47.4, 8.6, "8FVC9G8F+6X"},
+		// full codes are returned as they are
+		{"8FVC9G8F+6X", 0, 0, "8FVC9G8F+6X"},
+		// the nearest match is across the degree boundary from the reference location
+		{"2G22+22", 46.9999, 8.5, "8FVC2G22+22"},
+	}
+	for _, test := range tests {
+		got, err := RecoverNearest(test.code, test.refLat, test.refLng)
+		if err != nil {
+			t.Errorf("RecoverNearest(%q): unexpected error: %v", test.code, err)
+		} else if got != test.want {
+			t.Errorf("RecoverNearest(%q, %v, %v) = %v, want %v", test.code, test.refLat, test.refLng, got, test.want)
+		}
+	}
+
+	if _, err := RecoverNearest("9G8F+6", 47.4, 8.6); err == nil {
+		t.Error("expected an error for an invalid short code")
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/evanhutnik/wipercheck-service/internal/common"
+	"github.com/evanhutnik/wipercheck-service/internal/geocode"
 	t "github.com/evanhutnik/wipercheck-service/internal/types"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 )
 
 type ForwardResponse struct {
@@ -17,9 +19,10 @@ type ForwardResponse struct {
 }
 
 type Coordinate struct {
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
-	Label     string  `json:"label"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	Label      string  `json:"label"`
+	Confidence float64 `json:"confidence"`
 }
 
 type ReverseResponse struct {
@@ -68,17 +71,30 @@ func New(opts ...ClientOption) *Client {
 	return c
 }
 
-func (c *Client) GeoCode(ctx context.Context, location string) (*t.Coordinates, error) {
+func (c *Client) Search(ctx context.Context, query string, opts geocode.SearchOptions) ([]t.Place, error) {
 	req, err := url.Parse(fmt.Sprintf("%v/forward", c.baseUrl))
 	if err != nil {
 		err = errors.New(fmt.Sprintf("failed to parse positionstack baseUrl %s: %s", c.baseUrl, err.Error()))
 		return nil, err
 	}
 
+	limit := opts.Limit
+	if limit < 1 {
+		limit = 1
+	}
 	q := req.Query()
 	q.Add("access_key", c.apiKey)
-	q.Add("query", location)
-	q.Add("limit", "1")
+	q.Add("query", query)
+	q.Add("limit", strconv.Itoa(limit))
+	if opts.Country != "" {
+		q.Add("country", opts.Country)
+	}
+	if opts.Region != "" {
+		q.Add("region", opts.Region)
+	}
+	if opts.Lang != "" {
+		q.Add("language", opts.Lang)
+	}
 	req.RawQuery = q.Encode()
 
 	ctxReq, _ := http.NewRequestWithContext(ctx, "GET", req.String(), nil)
@@ -87,6 +103,7 @@ func (c *Client) GeoCode(ctx context.Context, location string) (*t.Coordinates,
 		return nil, err
 	}
 
+	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		err = errors.New(fmt.Sprintf("error reading positionstack response body: %s", err.Error()))
@@ -98,16 +115,23 @@ func (c *Client) GeoCode(ctx context.Context, location string) (*t.Coordinates,
 	if err != nil {
 		err = errors.New(fmt.Sprintf("error unmarshalling response from positionstack: %s", err.Error()))
 		return nil, err
-	} else if len(respObj.Data) == 0 {
-		return nil, nil
 	}
-	return &t.Coordinates{
-		Latitude:  respObj.Data[0].Latitude,
-		Longitude: respObj.Data[0].Longitude,
-	}, nil
+
+	var places []t.Place
+	for _, data := range respObj.Data {
+		places = append(places, t.Place{
+			Label: data.Label,
+			Coordinates: t.Coordinates{
+				Latitude:  data.Latitude,
+				Longitude: data.Longitude,
+			},
+			Confidence: data.Confidence,
+		})
+	}
+	return places, nil
 }
 
-func (c *Client) ReverseGeoCode(ctx context.Context, coords t.Coordinates) (*t.Location, error) {
+func (c *Client) ReverseGeoCode(ctx context.Context, coords t.Coordinates, lang string) (*t.Location, error) {
 	req, err := url.Parse(fmt.Sprintf("%v/reverse", c.baseUrl))
 	if err != nil {
 		err = errors.New(fmt.Sprintf("failed to parse positionstack baseUrl %s: %s", c.baseUrl, err.Error()))
@@ -118,6 +142,9 @@ func (c *Client) ReverseGeoCode(ctx context.Context, coords t.Coordinates) (*t.L
 	q.Add("access_key", c.apiKey)
 	q.Add("query", fmt.Sprintf("%v,%v", coords.Latitude, coords.Longitude))
 	q.Add("limit", "1")
+	if lang != "" {
+		q.Add("language", lang)
+	}
 	req.RawQuery = q.Encode()
 
 	ctxReq, _ := http.NewRequestWithContext(ctx, "GET", req.String(), nil)
@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"context"
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+)
+
+// vehicles that routes can be requested for
+const (
+	VehicleCar        = "car"
+	VehicleTruck      = "truck"
+	VehicleMotorcycle = "motorcycle"
+	VehicleBicycle    = "bicycle"
+	VehicleFoot       = "foot"
+)
+
+// road features that routes can avoid
+const (
+	AvoidTolls     = "tolls"
+	AvoidFerries   = "ferries"
+	AvoidMotorways = "motorways"
+)
+
+// Avoidable are all the road features that routes can avoid
+var Avoidable = []string{AvoidTolls, AvoidFerries, AvoidMotorways}
+
+// ModeFerry is the mode of steps taken on a ferry
+const ModeFerry = "ferry"
+
+// Vehicles are all the vehicles that routes can be requested for, although each router may only support some of them
+var Vehicles = []string{VehicleCar, VehicleTruck, VehicleMotorcycle, VehicleBicycle, VehicleFoot}
+
+// Router finds the route for a trip, such as OSRM, Valhalla or GraphHopper
+type Router interface {
+	// Route returns the route between the trip's coordinates, with its steps, geometry, distance in metres and duration in seconds
+	Route(ctx context.Context, trip *types.Trip, opts Options) (*types.Route, error)
+	// Supports returns whether the router can route the vehicle
+	Supports(vehicle string) bool
+	// SupportsAvoid returns whether the router can route the vehicle while avoiding all the road features together
+	SupportsAvoid(vehicle string, avoid []string) bool
+}
+
+// Tabler is implemented by routers that can find the durations and distances between many points at once
+type Tabler interface {
+	// Table returns the routes from each origin to each destination
+	Table(ctx context.Context, origins []types.Coordinates, destinations []types.Coordinates, opts Options) (*Table, error)
+}
+
+// Table has the duration in seconds and distance in metres from each origin (row) to each destination (column),
+// with every row having a column for each destination. Both are nil for pairs without a route
+type Table struct {
+	Durations [][]*float64
+	Distances [][]*float64
+}
+
+// Options change how a route is found
+type Options struct {
+	// Vehicle selects the router's profile, defaulting to VehicleCar
+	Vehicle string
+	// Avoid are the road features the route must not use, such as AvoidTolls
+	Avoid []string
+	// Via are points the route must pass through in order, e.g. to detour around bad weather
+	Via []types.Coordinates
+}
+
+// Motorized returns whether the vehicle drives on roads with tolls and motorways, which bicycles and pedestrians can't use
+func Motorized(vehicle string) bool {
+	return vehicle == VehicleCar || vehicle == VehicleTruck || vehicle == VehicleMotorcycle
+}
+
+// Avoids returns whether the options avoid the road feature
+func (o Options) Avoids(feature string) bool {
+	for _, avoid := range o.Avoid {
+		if avoid == feature {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+// Package testutil stubs the upstream apis in the adapters' tests
+package testutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Handler checks a request to a stubbed api and returns the status and body to answer it with, setting any other
+// response headers on header
+type Handler func(r *http.Request, header http.Header) (status int, body []byte)
+
+// Server starts a stub api answering each request with the handler's response, closed when the test ends
+func Server(t testing.TB, handle Handler) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		status, body := handle(r, w.Header())
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Testdata reads a recorded response from the testdata directory of the package under test
+func Testdata(t testing.TB, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read testdata: %v", err)
+	}
+	return body
+}
@@ -7,23 +7,56 @@ type SummaryStep struct {
 }
 
 type Route struct {
-	Steps    []Step
+	Steps []Step
+	// Geometry is the full path of the route
+	Geometry []Coordinates
 	Duration float64
+	Distance float64
 }
 
 type Step struct {
 	Name          string      `json:"name,omitempty"`
 	StepDuration  float64     `json:"stepDuration,omitempty"`
 	TotalDuration float64     `json:"totalDuration,omitempty"`
+	StepDistance  float64     `json:"stepDistance,omitempty"`
+	TotalDistance float64     `json:"totalDistance,omitempty"`
+	Arrival       int64       `json:"eta,omitempty"`
 	Coordinates   Coordinates `json:"coordinates,omitempty"`
 	Weather       *Weather    `json:"weather,omitempty"`
 	Location      *Location   `json:"location,omitempty"`
+	// Mode is 'ferry' for steps taken on a ferry
+	Mode string `json:"mode,omitempty"`
+	// Hazards are the reasons the step's weather is hazardous for the requested vehicle
+	Hazards []string `json:"hazards,omitempty"`
+}
+
+type TripSummary struct {
+	DurationMinutes float64    `json:"durationMinutes"`
+	DistanceKm      float64    `json:"distanceKm"`
+	Exposure        []Exposure `json:"exposure,omitempty"`
+	WorstStep       *Step      `json:"worstStep,omitempty"`
+	HazardMinutes   float64    `json:"hazardMinutes"`
+	FirstWetETA     int64      `json:"firstWetEta,omitempty"`
+	LastWetETA      int64      `json:"lastWetEta,omitempty"`
+	WiperIndex      float64    `json:"wiperIndex"`
+}
+
+type Exposure struct {
+	MinPop  float64 `json:"minPrecipChance"`
+	Minutes float64 `json:"minutes"`
+	Km      float64 `json:"km"`
 }
 
 type Weather struct {
 	Time       int64      `json:"-"`
+	Timezone   string     `json:"-"`
 	Pop        float64    `json:"precipChance"`
+	Temp       float64    `json:"temp"`
+	WindSpeed  float64    `json:"windSpeed"`
+	WindGust   float64    `json:"windGust,omitempty"`
 	Conditions Conditions `json:"conditions,omitempty"`
+	Spread     *float64   `json:"spread,omitempty"`
+	Sources    []string   `json:"sources,omitempty"`
 }
 
 type Conditions struct {
@@ -55,3 +88,19 @@ type RedisHourlyWeather struct {
 	Rand   float64
 	Hourly *Weather
 }
+
+type ForecastWindow struct {
+	Coordinates Coordinates      `json:"coordinates"`
+	Hours       []HourlyForecast `json:"hours"`
+}
+
+type HourlyForecast struct {
+	Time    int64    `json:"time"`
+	Weather *Weather `json:"weather"`
+}
+
+type Place struct {
+	Label       string      `json:"label"`
+	Coordinates Coordinates `json:"coordinates"`
+	Confidence  float64     `json:"confidence"`
+}
@@ -0,0 +1,224 @@
+package valhalla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/routing"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"io"
+	"net/http"
+)
+
+type Request struct {
+	Locations         []Location                 `json:"locations"`
+	Costing           string                     `json:"costing"`
+	CostingOptions    map[string]map[string]bool `json:"costing_options,omitempty"`
+	DirectionsOptions DirectionsOptions          `json:"directions_options"`
+}
+
+type Location struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+	// Type is 'through' for via points, which are passed through without stopping
+	Type string `json:"type,omitempty"`
+}
+
+type DirectionsOptions struct {
+	Units string `json:"units"`
+}
+
+type Response struct {
+	Trip Trip `json:"trip"`
+	// Error is set instead of the trip when no route could be found
+	Error string `json:"error"`
+}
+
+type Trip struct {
+	Legs    []Leg   `json:"legs"`
+	Summary Summary `json:"summary"`
+}
+
+type Leg struct {
+	Maneuvers []Maneuver `json:"maneuvers"`
+	Shape     string     `json:"shape"`
+	Summary   Summary    `json:"summary"`
+}
+
+// Summary has the length in kilometres and time in seconds
+type Summary struct {
+	Length float64 `json:"length"`
+	Time   float64 `json:"time"`
+}
+
+type Maneuver struct {
+	Type            int      `json:"type"`
+	Instruction     string   `json:"instruction"`
+	StreetNames     []string `json:"street_names"`
+	Length          float64  `json:"length"`
+	Time            float64  `json:"time"`
+	BeginShapeIndex int      `json:"begin_shape_index"`
+	EndShapeIndex   int      `json:"end_shape_index"`
+	TravelMode      string   `json:"travel_mode"`
+}
+
+// ferryEnterType is the maneuver type for boarding a ferry, which lasts until the ferry is exited
+const ferryEnterType = 28
+
+type ClientOption func(*Client)
+
+// costings are valhalla's costing models for each vehicle
+// https://valhalla.github.io/valhalla/api/turn-by-turn/api-reference/#costing-models
+var costings = map[string]string{
+	routing.VehicleCar:        "auto",
+	routing.VehicleTruck:      "truck",
+	routing.VehicleMotorcycle: "motorcycle",
+	routing.VehicleBicycle:    "bicycle",
+	routing.VehicleFoot:       "pedestrian",
+}
+
+type Client struct {
+	baseUrl string
+}
+
+func BaseUrlOption(baseUrl string) ClientOption {
+	return func(c *Client) {
+		c.baseUrl = baseUrl
+	}
+}
+
+func New(opts ...ClientOption) *Client {
+	c := &Client{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.baseUrl == "" {
+		panic("Missing baseUrl in valhalla client")
+	}
+	return c
+}
+
+func (c *Client) Supports(vehicle string) bool {
+	_, ok := costings[vehicle]
+	return ok
+}
+
+// SupportsAvoid only allows motorized vehicles to avoid road features, as valhalla's exclude options are only
+// available to the motor vehicle costings
+func (c *Client) SupportsAvoid(vehicle string, avoid []string) bool {
+	return len(avoid) == 0 || routing.Motorized(vehicle)
+}
+
+func (c *Client) Route(ctx context.Context, trip *t.Trip, opts routing.Options) (*t.Route, error) {
+	vehicle := opts.Vehicle
+	if vehicle == "" {
+		vehicle = routing.VehicleCar
+	}
+	costing, ok := costings[vehicle]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("no valhalla costing for vehicle %v", vehicle))
+	}
+	locations := []Location{{Lat: trip.From.Latitude, Lon: trip.From.Longitude}}
+	for _, via := range opts.Via {
+		locations = append(locations, Location{Lat: via.Latitude, Lon: via.Longitude, Type: "through"})
+	}
+	locations = append(locations, Location{Lat: trip.To.Latitude, Lon: trip.To.Longitude})
+	reqBody, _ := json.Marshal(Request{
+		Locations:         locations,
+		Costing:           costing,
+		CostingOptions:    costingOptions(costing, opts),
+		DirectionsOptions: DirectionsOptions{Units: "kilometers"},
+	})
+
+	ctxReq, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%v/route", c.baseUrl), bytes.NewReader(reqBody))
+	if err != nil {
+		err = errors.New(fmt.Sprintf("failed to create valhalla request for baseUrl %s: %s", c.baseUrl, err.Error()))
+		return nil, err
+	}
+	ctxReq.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(ctxReq)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error on valhalla api request: %s", err.Error()))
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error reading valhalla response body: %s", err.Error()))
+		return nil, err
+	}
+
+	var respObj Response
+	err = json.Unmarshal(body, &respObj)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("error unmarshalling response from valhalla: %s", err.Error()))
+		return nil, err
+	} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = errors.New(fmt.Sprintf("error code %d returned from valhalla: %s", resp.StatusCode, respObj.Error))
+		return nil, err
+	} else if len(respObj.Trip.Legs) == 0 {
+		return nil, errors.New("no route returned from valhalla")
+	}
+
+	var steps []t.Step
+	var geometry []t.Coordinates
+	for _, leg := range respObj.Trip.Legs {
+		shape := decodePolyline(leg.Shape)
+		steps = append(steps, c.routeStepsFromValhalla(leg.Maneuvers, shape)...)
+		geometry = append(geometry, shape...)
+	}
+	return &t.Route{
+		Steps:    steps,
+		Geometry: geometry,
+		Duration: respObj.Trip.Summary.Time,
+		Distance: respObj.Trip.Summary.Length * 1000,
+	}, nil
+}
+
+// costingOptions returns the costing options excluding the road features outright, rather than the use_* options
+// which only make valhalla prefer other roads
+func costingOptions(costing string, opts routing.Options) map[string]map[string]bool {
+	options := make(map[string]bool)
+	if opts.Avoids(routing.AvoidTolls) {
+		options["exclude_tolls"] = true
+	}
+	if opts.Avoids(routing.AvoidFerries) {
+		options["exclude_ferries"] = true
+	}
+	if opts.Avoids(routing.AvoidMotorways) {
+		options["exclude_highways"] = true
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return map[string]map[string]bool{costing: options}
+}
+
+// routeStepsFromValhalla converts maneuvers to steps, located at the start of each maneuver's shape
+func (c *Client) routeStepsFromValhalla(maneuvers []Maneuver, shape []t.Coordinates) []t.Step {
+	var routeSteps []t.Step
+	for _, maneuver := range maneuvers {
+		if maneuver.BeginShapeIndex >= len(shape) {
+			continue
+		}
+		var name string
+		if len(maneuver.StreetNames) > 0 {
+			name = maneuver.StreetNames[0]
+		}
+		var mode string
+		if maneuver.Type == ferryEnterType {
+			mode = routing.ModeFerry
+		}
+		routeSteps = append(routeSteps, t.Step{
+			Name:         name,
+			Mode:         mode,
+			StepDuration: maneuver.Time,
+			StepDistance: maneuver.Length * 1000,
+			Coordinates:  shape[maneuver.BeginShapeIndex],
+		})
+	}
+	return routeSteps
+}
@@ -0,0 +1,142 @@
+package valhalla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/routing"
+	"github.com/evanhutnik/wipercheck-service/internal/testutil"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var trip = &t.Trip{
+	From: &t.Coordinates{Latitude: 47.6062, Longitude: -122.3321},
+	To:   &t.Coordinates{Latitude: 47.6257, Longitude: -122.5212},
+}
+
+// valhallaServer stubs the route endpoint with a recorded response, passing each decoded request to check
+func valhallaServer(tt *testing.T, status int, file string, check func(req Request)) *httptest.Server {
+	tt.Helper()
+	body := testutil.Testdata(tt, file)
+	return testutil.Server(tt, func(r *http.Request, _ http.Header) (int, []byte) {
+		if r.Method != http.MethodPost || r.URL.Path != "/route" {
+			tt.Errorf("unexpected request %v %v", r.Method, r.URL)
+		}
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			tt.Errorf("invalid request body: %v", err)
+		}
+		check(req)
+		return status, body
+	})
+}
+
+func TestRoute(tt *testing.T) {
+	via := t.Coordinates{Latitude: 47.6028, Longitude: -122.3386}
+	server := valhallaServer(tt, http.StatusOK, "route_ferry.json", func(req Request) {
+		if req.Costing != "auto" {
+			tt.Errorf("expected auto costing, got %q", req.Costing)
+		}
+		if req.DirectionsOptions.Units != "kilometers" {
+			tt.Errorf("expected kilometers, got %q", req.DirectionsOptions.Units)
+		}
+		if req.CostingOptions != nil {
+			tt.Errorf("expected no costing options, got %v", req.CostingOptions)
+		}
+		want := []Location{
+			{Lat: trip.From.Latitude, Lon: trip.From.Longitude},
+			{Lat: via.Latitude, Lon: via.Longitude, Type: "through"},
+			{Lat: trip.To.Latitude, Lon: trip.To.Longitude},
+		}
+		if fmt.Sprint(req.Locations) != fmt.Sprint(want) {
+			tt.Errorf("expected locations %v, got %v", want, req.Locations)
+		}
+	})
+	c := New(BaseUrlOption(server.URL))
+
+	route, err := c.Route(context.Background(), trip, routing.Options{Via: []t.Coordinates{via}})
+	if err != nil {
+		tt.Fatalf("unexpected error: %v", err)
+	}
+	if route.Distance != 15670 || route.Duration != 2328.8 {
+		tt.Errorf("expected 15670 m in 2328.8 s, got %v m in %v s", route.Distance, route.Duration)
+	}
+	if len(route.Geometry) != 8 || route.Geometry[2] != via {
+		tt.Errorf("expected the decoded shape, got %v", route.Geometry)
+	}
+
+	want := []t.Step{
+		{Name: "Madison Street", StepDistance: 320, StepDuration: 51.118, Coordinates: *trip.From},
+		{Name: "Alaskan Way", StepDistance: 330, StepDuration: 62.447, Coordinates: t.Coordinates{Latitude: 47.6049, Longitude: -122.3354}},
+		{Name: "Bainbridge Island - Seattle", Mode: routing.ModeFerry, StepDistance: 14100, StepDuration: 2100, Coordinates: via},
+		{Name: "Olympic Drive Southeast", StepDistance: 370, StepDuration: 45.201, Coordinates: t.Coordinates{Latitude: 47.6229, Longitude: -122.5098}},
+		{Name: "Winslow Way East", StepDistance: 550, StepDuration: 70.034, Coordinates: t.Coordinates{Latitude: 47.6241, Longitude: -122.514}},
+		{Coordinates: *trip.To},
+	}
+	if len(route.Steps) != len(want) {
+		tt.Fatalf("expected %d steps, got %+v", len(want), route.Steps)
+	}
+	for i, step := range route.Steps {
+		if step.Name != want[i].Name || step.Mode != want[i].Mode || step.StepDistance != want[i].StepDistance ||
+			step.StepDuration != want[i].StepDuration || step.Coordinates != want[i].Coordinates {
+			tt.Errorf("step %d: expected %+v, got %+v", i, want[i], step)
+		}
+	}
+}
+
+func TestRouteAvoid(tt *testing.T) {
+	server := valhallaServer(tt, http.StatusOK, "route_ferry.json", func(req Request) {
+		if req.Costing != "truck" {
+			tt.Errorf("expected truck costing, got %q", req.Costing)
+		}
+		want := map[string]map[string]bool{"truck": {"exclude_ferries": true, "exclude_highways": true, "exclude_tolls": true}}
+		if fmt.Sprint(req.CostingOptions) != fmt.Sprint(want) {
+			tt.Errorf("expected costing options %v, got %v", want, req.CostingOptions)
+		}
+	})
+	c := New(BaseUrlOption(server.URL))
+
+	opts := routing.Options{Vehicle: routing.VehicleTruck, Avoid: routing.Avoidable}
+	if _, err := c.Route(context.Background(), trip, opts); err != nil {
+		tt.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRouteError(tt *testing.T) {
+	server := valhallaServer(tt, http.StatusBadRequest, "route_error.json", func(req Request) {})
+	c := New(BaseUrlOption(server.URL))
+
+	_, err := c.Route(context.Background(), trip, routing.Options{})
+	if err == nil || !strings.Contains(err.Error(), "No path could be found for input") {
+		tt.Errorf("expected valhalla's error when no route is found, got %v", err)
+	}
+}
+
+func TestSupports(tt *testing.T) {
+	c := New(BaseUrlOption("http://localhost"))
+	for _, vehicle := range routing.Vehicles {
+		if !c.Supports(vehicle) {
+			tt.Errorf("expected %v to be supported", vehicle)
+		}
+	}
+	if c.Supports("boat") {
+		tt.Error("expected boat to be unsupported")
+	}
+}
+
+func TestSupportsAvoid(tt *testing.T) {
+	c := New(BaseUrlOption("http://localhost"))
+	if !c.SupportsAvoid(routing.VehicleTruck, routing.Avoidable) {
+		tt.Error("expected trucks to avoid all features")
+	}
+	if !c.SupportsAvoid(routing.VehicleBicycle, nil) {
+		tt.Error("expected bicycles to route without avoiding anything")
+	}
+	if c.SupportsAvoid(routing.VehicleFoot, []string{routing.AvoidFerries}) {
+		tt.Error("expected pedestrians not to avoid ferries")
+	}
+}
@@ -0,0 +1,40 @@
+package valhalla
+
+import t "github.com/evanhutnik/wipercheck-service/internal/types"
+
+// valhalla encodes shapes as polylines with 6 decimal digits of precision
+// https://valhalla.github.io/valhalla/decoding/
+const precision = 1e6
+
+// decodePolyline decodes an encoded polyline shape into coordinates
+func decodePolyline(encoded string) []t.Coordinates {
+	var coords []t.Coordinates
+	var lat, lon int
+	for i := 0; i < len(encoded); {
+		var deltas [2]int
+		for d := range deltas {
+			var result, shift int
+			for i < len(encoded) {
+				b := int(encoded[i]) - 63
+				i++
+				result |= (b & 0x1f) << shift
+				shift += 5
+				if b < 0x20 {
+					break
+				}
+			}
+			if result&1 != 0 {
+				deltas[d] = ^(result >> 1)
+			} else {
+				deltas[d] = result >> 1
+			}
+		}
+		lat += deltas[0]
+		lon += deltas[1]
+		coords = append(coords, t.Coordinates{
+			Latitude:  float64(lat) / precision,
+			Longitude: float64(lon) / precision,
+		})
+	}
+	return coords
+}
@@ -0,0 +1,33 @@
+package valhalla
+
+import (
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"testing"
+)
+
+func TestDecodePolyline(tt *testing.T) {
+	tests := []struct {
+		encoded string
+		want    []t.Coordinates
+	}{
+		{"", nil},
+		{"_p~iF~ps|U", []t.Coordinates{{Latitude: 3.85, Longitude: -12.02}}},
+		{"_p~iF~ps|U_ulLnnqC_mqNvxq`@", []t.Coordinates{
+			{Latitude: 3.85, Longitude: -12.02},
+			{Latitude: 4.07, Longitude: -12.095},
+			{Latitude: 4.3252, Longitude: -12.6453},
+		}},
+	}
+	for _, test := range tests {
+		got := decodePolyline(test.encoded)
+		if len(got) != len(test.want) {
+			tt.Errorf("decodePolyline(%q) = %v, want %v", test.encoded, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				tt.Errorf("decodePolyline(%q)[%d] = %v, want %v", test.encoded, i, got[i], test.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// healthWindow is the number of recent requests a provider's health is calculated from
+	healthWindow = 20
+	// minHealthSamples is the number of requests needed before a provider can be considered unhealthy
+	minHealthSamples = 3
+	// maxErrorRate is the recent error rate at which a provider is skipped for the cool-down period
+	maxErrorRate = 0.5
+)
+
+// Chain is a Provider that tries its sources in order, skipping sources that are failing for a cool-down period
+type Chain struct {
+	sources  []Source
+	health   []*health
+	cooldown time.Duration
+}
+
+// SourceHealth is a snapshot of a source's recent requests
+type SourceHealth struct {
+	Name          string     `json:"name"`
+	ErrorRate     float64    `json:"errorRate"`
+	LatencyMs     float64    `json:"latencyMs"`
+	Requests      int        `json:"requests"`
+	CoolDownUntil *time.Time `json:"coolDownUntil,omitempty"`
+}
+
+// health tracks the outcome of a source's recent requests
+type health struct {
+	mu            sync.Mutex
+	failures      []bool
+	latencies     []time.Duration
+	coolDownUntil time.Time
+}
+
+func NewChain(cooldown time.Duration, sources ...Source) *Chain {
+	if len(sources) == 0 {
+		panic("Missing sources in weather chain")
+	}
+	c := &Chain{
+		sources:  sources,
+		cooldown: cooldown,
+	}
+	for range sources {
+		c.health = append(c.health, &health{})
+	}
+	return c
+}
+
+func (c *Chain) GetHourlyWeather(ctx context.Context, coords types.Coordinates, time int64, lang string) (*types.Weather, error) {
+	var errs []string
+	for _, i := range c.order() {
+		result, err := c.call(ctx, i, func(p Provider) (interface{}, error) {
+			return p.GetHourlyWeather(ctx, coords, time, lang)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", c.sources[i].Name, err.Error()))
+			continue
+		}
+		hourly := result.(*types.Weather)
+		hourly.Sources = []string{c.sources[i].Name}
+		return hourly, nil
+	}
+	return nil, errors.New(fmt.Sprintf("all weather providers failed (%v)", strings.Join(errs, "; ")))
+}
+
+func (c *Chain) GetWeather(ctx context.Context, lat float64, long float64, lang string) ([]types.Weather, error) {
+	var errs []string
+	for _, i := range c.order() {
+		result, err := c.call(ctx, i, func(p Provider) (interface{}, error) {
+			return p.GetWeather(ctx, lat, long, lang)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", c.sources[i].Name, err.Error()))
+			continue
+		}
+		hourly := result.([]types.Weather)
+		for j := range hourly {
+			hourly[j].Sources = []string{c.sources[i].Name}
+		}
+		return hourly, nil
+	}
+	return nil, errors.New(fmt.Sprintf("all weather providers failed (%v)", strings.Join(errs, "; ")))
+}
+
+// Health returns the recent error rate and latency of each source
+func (c *Chain) Health() []SourceHealth {
+	var snapshots []SourceHealth
+	for i, h := range c.health {
+		snapshot := h.snapshot()
+		snapshot.Name = c.sources[i].Name
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+// order returns the indexes of the sources to try, skipping sources that are cooling down unless all of them are
+func (c *Chain) order() []int {
+	var healthy, coolingDown []int
+	now := time.Now()
+	for i, h := range c.health {
+		if h.coolingDown(now) {
+			coolingDown = append(coolingDown, i)
+		} else {
+			healthy = append(healthy, i)
+		}
+	}
+	if len(healthy) == 0 {
+		return coolingDown
+	}
+	return healthy
+}
+
+// call makes a request to the source, recording its outcome
+func (c *Chain) call(ctx context.Context, i int, request func(p Provider) (interface{}, error)) (interface{}, error) {
+	start := time.Now()
+	result, err := request(c.sources[i].Provider)
+	// requests cancelled by the caller say nothing about the source's health, nor do hours past the end of its forecast
+	if ctx.Err() == nil {
+		c.health[i].record(err != nil && !errors.Is(err, ErrOutOfRange), time.Since(start), c.cooldown)
+	}
+	return result, err
+}
+
+func (h *health) record(failed bool, latency time.Duration, cooldown time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures = append(h.failures, failed)
+	h.latencies = append(h.latencies, latency)
+	if len(h.failures) > healthWindow {
+		h.failures = h.failures[1:]
+		h.latencies = h.latencies[1:]
+	}
+	if failed && len(h.failures) >= minHealthSamples && h.errorRate() >= maxErrorRate {
+		h.coolDownUntil = time.Now().Add(cooldown)
+		// starting over after the cool-down so a recovered source isn't immediately skipped again
+		h.failures, h.latencies = nil, nil
+	}
+}
+
+func (h *health) coolingDown(now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return now.Before(h.coolDownUntil)
+}
+
+func (h *health) snapshot() SourceHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	snapshot := SourceHealth{
+		ErrorRate: h.errorRate(),
+		Requests:  len(h.failures),
+	}
+	if time.Now().Before(h.coolDownUntil) {
+		coolDownUntil := h.coolDownUntil
+		snapshot.CoolDownUntil = &coolDownUntil
+	}
+	var total time.Duration
+	for _, latency := range h.latencies {
+		total += latency
+	}
+	if len(h.latencies) > 0 {
+		snapshot.LatencyMs = float64(total.Milliseconds()) / float64(len(h.latencies))
+	}
+	return snapshot
+}
+
+// errorRate must be called with the lock held
+func (h *health) errorRate() float64 {
+	if len(h.failures) == 0 {
+		return 0
+	}
+	var failed int
+	for _, f := range h.failures {
+		if f {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(h.failures))
+}
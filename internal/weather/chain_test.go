@@ -0,0 +1,76 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+	"testing"
+	"time"
+)
+
+// fakeProvider returns its error if set, or otherwise a forecast for every hour
+type fakeProvider struct {
+	err   error
+	calls int
+}
+
+func (p *fakeProvider) GetHourlyWeather(ctx context.Context, coords types.Coordinates, time int64, lang string) (*types.Weather, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &types.Weather{Time: time}, nil
+}
+
+func (p *fakeProvider) GetWeather(ctx context.Context, lat float64, long float64, lang string) ([]types.Weather, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return []types.Weather{{}}, nil
+}
+
+func TestChainCoolDown(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		coolingDown  bool
+		primaryCalls int
+	}{
+		{"healthy", nil, false, minHealthSamples + 2},
+		{"failing", errors.New("error code 500 returned from openweather"), true, minHealthSamples},
+		{"out of range", ErrOutOfRange, false, minHealthSamples + 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			primary, fallback := &fakeProvider{err: test.err}, &fakeProvider{}
+			c := NewChain(time.Minute, Source{Name: "primary", Provider: primary}, Source{Name: "fallback", Provider: fallback})
+
+			for i := 0; i < minHealthSamples+2; i++ {
+				if _, err := c.GetHourlyWeather(context.Background(), types.Coordinates{}, 3600, "en"); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if coolingDown := c.Health()[0].CoolDownUntil != nil; coolingDown != test.coolingDown {
+				t.Errorf("expected the primary cooling down to be %v", test.coolingDown)
+			}
+			if primary.calls != test.primaryCalls {
+				t.Errorf("expected the primary to be tried %d times, got %d", test.primaryCalls, primary.calls)
+			}
+		})
+	}
+}
+
+func TestChainAllCoolingDown(t *testing.T) {
+	failing := &fakeProvider{err: errors.New("timeout")}
+	c := NewChain(time.Minute, Source{Name: "only", Provider: failing})
+	for i := 0; i < minHealthSamples; i++ {
+		c.GetHourlyWeather(context.Background(), types.Coordinates{}, 3600, "en")
+	}
+
+	// a cooling down provider is still tried when there is nothing else to try
+	failing.err = nil
+	if _, err := c.GetHourlyWeather(context.Background(), types.Coordinates{}, 3600, "en"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
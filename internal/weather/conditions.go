@@ -0,0 +1,59 @@
+package weather
+
+import (
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/i18n"
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+)
+
+type condition struct {
+	main string
+	icon string
+}
+
+// conditions are the OpenWeather conditions other providers' weather codes are mapped to, keyed by condition id
+// https://openweathermap.org/weather-conditions#Weather-Condition-Codes-2
+var conditions = map[int]condition{
+	200: {"Thunderstorm", "11d"},
+	201: {"Thunderstorm", "11d"},
+	202: {"Thunderstorm", "11d"},
+	211: {"Thunderstorm", "11d"},
+	300: {"Drizzle", "09d"},
+	301: {"Drizzle", "09d"},
+	302: {"Drizzle", "09d"},
+	500: {"Rain", "10d"},
+	501: {"Rain", "10d"},
+	502: {"Rain", "10d"},
+	511: {"Rain", "13d"},
+	520: {"Rain", "09d"},
+	521: {"Rain", "09d"},
+	522: {"Rain", "09d"},
+	600: {"Snow", "13d"},
+	601: {"Snow", "13d"},
+	602: {"Snow", "13d"},
+	611: {"Snow", "13d"},
+	616: {"Snow", "13d"},
+	620: {"Snow", "13d"},
+	622: {"Snow", "13d"},
+	701: {"Mist", "50d"},
+	741: {"Fog", "50d"},
+	800: {"Clear", "01d"},
+	801: {"Clouds", "02d"},
+	802: {"Clouds", "03d"},
+	803: {"Clouds", "04d"},
+	804: {"Clouds", "04d"},
+}
+
+// Conditions returns the OpenWeather conditions for the condition id, with the description in the requested language
+func Conditions(id int, lang string) types.Conditions {
+	c, ok := conditions[id]
+	if !ok {
+		return types.Conditions{}
+	}
+	return types.Conditions{
+		Id:          id,
+		Main:        c.main,
+		Description: i18n.Sprintf(lang, fmt.Sprintf("conditions.%d", id)),
+		IconURL:     fmt.Sprintf("http://openweathermap.org/img/wn/%v@2x.png", c.icon),
+	}
+}
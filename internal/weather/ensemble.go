@@ -0,0 +1,147 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Source is a named weather provider taking part in an ensemble
+type Source struct {
+	Name     string
+	Provider Provider
+	Weight   float64
+}
+
+// Ensemble is a Provider that queries several sources and combines their forecasts with weights
+type Ensemble struct {
+	sources []Source
+}
+
+func NewEnsemble(sources ...Source) *Ensemble {
+	if len(sources) == 0 {
+		panic("Missing sources in weather ensemble")
+	}
+	return &Ensemble{sources: sources}
+}
+
+// sourceWeather is a single source's forecast for an hour
+type sourceWeather struct {
+	source  Source
+	weather *types.Weather
+}
+
+func (e *Ensemble) GetHourlyWeather(ctx context.Context, coords types.Coordinates, time int64, lang string) (*types.Weather, error) {
+	results := make([]*types.Weather, len(e.sources))
+	errs := make([]error, len(e.sources))
+	wg := new(sync.WaitGroup)
+	wg.Add(len(e.sources))
+	for i, source := range e.sources {
+		i, source := i, source
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = source.Provider.GetHourlyWeather(ctx, coords, time, lang)
+		}()
+	}
+	wg.Wait()
+
+	var forecasts []sourceWeather
+	for i, result := range results {
+		if errs[i] == nil && result != nil {
+			forecasts = append(forecasts, sourceWeather{source: e.sources[i], weather: result})
+		}
+	}
+	if len(forecasts) == 0 {
+		return nil, e.error(errs)
+	}
+	return combine(forecasts), nil
+}
+
+func (e *Ensemble) GetWeather(ctx context.Context, lat float64, long float64, lang string) ([]types.Weather, error) {
+	results := make([][]types.Weather, len(e.sources))
+	errs := make([]error, len(e.sources))
+	wg := new(sync.WaitGroup)
+	wg.Add(len(e.sources))
+	for i, source := range e.sources {
+		i, source := i, source
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = source.Provider.GetWeather(ctx, lat, long, lang)
+		}()
+	}
+	wg.Wait()
+
+	// grouping the forecasts of every source by hour
+	hours := make(map[int64][]sourceWeather)
+	for i, result := range results {
+		if errs[i] != nil {
+			continue
+		}
+		for j := range result {
+			hours[result[j].Time] = append(hours[result[j].Time], sourceWeather{source: e.sources[i], weather: &result[j]})
+		}
+	}
+	if len(hours) == 0 {
+		return nil, e.error(errs)
+	}
+
+	var hourly []types.Weather
+	for _, forecasts := range hours {
+		hourly = append(hourly, *combine(forecasts))
+	}
+	sort.Slice(hourly, func(i, j int) bool {
+		return hourly[i].Time < hourly[j].Time
+	})
+	return hourly, nil
+}
+
+// combine calculates the weighted forecast of the sources, along with how much they disagree on the chance of precipitation
+func combine(forecasts []sourceWeather) *types.Weather {
+	combined := &types.Weather{
+		Time: forecasts[0].weather.Time,
+	}
+	var totalWeight, bestWeight float64
+	minPop, maxPop := math.Inf(1), math.Inf(-1)
+	for _, f := range forecasts {
+		w, weight := f.weather, f.source.Weight
+		totalWeight += weight
+		combined.Pop += w.Pop * weight
+		combined.Temp += w.Temp * weight
+		combined.WindSpeed += w.WindSpeed * weight
+		combined.WindGust += w.WindGust * weight
+		minPop, maxPop = math.Min(minPop, w.Pop), math.Max(maxPop, w.Pop)
+		// conditions can't be averaged, so using those of the most trusted source
+		if weight > bestWeight {
+			bestWeight = weight
+			combined.Conditions = w.Conditions
+		}
+		if combined.Timezone == "" {
+			combined.Timezone = w.Timezone
+		}
+		combined.Sources = append(combined.Sources, f.source.Name)
+	}
+	if totalWeight > 0 {
+		combined.Pop /= totalWeight
+		combined.Temp /= totalWeight
+		combined.WindSpeed /= totalWeight
+		combined.WindGust /= totalWeight
+	}
+	spread := maxPop - minPop
+	combined.Spread = &spread
+	return combined
+}
+
+func (e *Ensemble) error(errs []error) error {
+	var msgs []string
+	for i, err := range errs {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("%v: %v", e.sources[i].Name, err.Error()))
+		}
+	}
+	return errors.New(fmt.Sprintf("no weather sources returned a forecast (%v)", strings.Join(msgs, "; ")))
+}
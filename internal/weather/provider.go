@@ -0,0 +1,18 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"github.com/evanhutnik/wipercheck-service/internal/types"
+)
+
+// ErrOutOfRange is returned for hours beyond the end of a provider's forecast, which says nothing about its health
+var ErrOutOfRange = errors.New("no hourly weather found for time")
+
+// Provider is a source of forecasted hourly weather data, such as OpenWeather or Open-Meteo
+type Provider interface {
+	// GetHourlyWeather returns the forecasted weather at the coordinates for the hour starting at the unix time
+	GetHourlyWeather(ctx context.Context, coords types.Coordinates, time int64, lang string) (*types.Weather, error)
+	// GetWeather returns the hourly forecast at the coordinates
+	GetWeather(ctx context.Context, lat float64, long float64, lang string) ([]types.Weather, error)
+}
@@ -0,0 +1,223 @@
+package wipercheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/common"
+	"github.com/evanhutnik/wipercheck-service/internal/geocode"
+	"github.com/evanhutnik/wipercheck-service/internal/i18n"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// autocompleteTTL is how long autocomplete results are reused for repeated queries
+	autocompleteTTL = 30 * time.Second
+	// maxAutocompleteEntries is the most cached queries, at which expired entries are pruned and otherwise the oldest evicted
+	maxAutocompleteEntries   = 1000
+	defaultAutocompleteLimit = 5
+	maxAutocompleteLimit     = 10
+	// minAutocompleteLength is the shortest query that is sent to the geocoder
+	minAutocompleteLength = 2
+	// proximityKm is the distance at which a place's score is halved when biasing results towards a location
+	proximityKm = 50
+)
+
+type AutocompleteResponse struct {
+	Error  string              `json:"error,omitempty"`
+	Places []AutocompletePlace `json:"places"`
+}
+
+type AutocompletePlace struct {
+	t.Place
+	// Value is the place's coordinates formatted to be sent as 'from' or 'to' to /journey
+	Value string `json:"value"`
+}
+
+// autocompleteCache keeps recent autocomplete results so that repeated and extended queries skip the geocoder
+type autocompleteCache struct {
+	mu      sync.Mutex
+	entries map[string]autocompleteEntry
+}
+
+type autocompleteEntry struct {
+	places  []t.Place
+	limit   int
+	expires time.Time
+}
+
+func newAutocompleteCache() *autocompleteCache {
+	return &autocompleteCache{entries: make(map[string]autocompleteEntry)}
+}
+
+// AutocompleteHandler is the handler for the /places/autocomplete endpoint, returning places matching a partial address
+func (s *Service) AutocompleteHandler(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	opts := geocode.SearchOptions{
+		Limit:        defaultAutocompleteLimit,
+		Country:      r.URL.Query().Get("country"),
+		Lang:         r.URL.Query().Get("lang"),
+		Autocomplete: true,
+	}
+	if opts.Lang != "" && !i18n.Supported(opts.Lang) {
+		writeAutocompleteError(w, CodeError{code: 400, msg: fmt.Sprintf("Unsupported 'lang' parameter '%v'", opts.Lang)})
+		return
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxAutocompleteLimit {
+			writeAutocompleteError(w, CodeError{code: 400, msg: fmt.Sprintf("'limit' parameter must be between 1 and %v", maxAutocompleteLimit)})
+			return
+		}
+		opts.Limit = n
+	}
+	if near := r.URL.Query().Get("near"); near != "" {
+		match := decimalPattern.FindStringSubmatch(near)
+		if match == nil {
+			writeAutocompleteError(w, CodeError{code: 400, msg: "'near' parameter must be coordinates formatted as latitude,longitude"})
+			return
+		}
+		coords, err := decimalCoordinates(near, match)
+		if err != nil {
+			writeAutocompleteError(w, err)
+			return
+		}
+		opts.Near = coords
+	}
+
+	resp := AutocompleteResponse{Places: []AutocompletePlace{}}
+	// very short queries match too much to be useful, so returning nothing until more is typed
+	if len([]rune(q)) < minAutocompleteLength {
+		writeAutocompleteResponse(w, resp)
+		return
+	}
+
+	places, err := s.autocomplete(r, q, opts)
+	if err != nil {
+		s.Logger.Errorw(err.Error(), "query", q, "action", "Autocomplete")
+		writeAutocompleteError(w, CodeError{code: 500, msg: "Internal error autocompleting address."})
+		return
+	}
+	for _, place := range places {
+		resp.Places = append(resp.Places, AutocompletePlace{
+			Place: place,
+			// formatting without exponents so the value is accepted back as from/to coordinates
+			Value: strconv.FormatFloat(place.Coordinates.Latitude, 'f', -1, 64) + "," +
+				strconv.FormatFloat(place.Coordinates.Longitude, 'f', -1, 64),
+		})
+	}
+	writeAutocompleteResponse(w, resp)
+}
+
+// autocomplete returns the places matching the partial query, from the cache if possible
+func (s *Service) autocomplete(r *http.Request, q string, opts geocode.SearchOptions) ([]t.Place, error) {
+	query := strings.ToLower(q)
+	if places, ok := s.autocompletes.get(query, opts); ok {
+		return places, nil
+	}
+	places, err := s.gc.Search(r.Context(), q, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Near != nil {
+		rankByProximity(places, *opts.Near)
+	}
+	s.autocompletes.put(query, opts, places)
+	return places, nil
+}
+
+// rankByProximity orders the places by their confidence, reduced the further they are from the location
+func rankByProximity(places []t.Place, near t.Coordinates) {
+	score := func(place t.Place) float64 {
+		return place.Confidence / (1 + common.Distance(place.Coordinates, near)/proximityKm)
+	}
+	sort.SliceStable(places, func(i, j int) bool {
+		return score(places[i]) > score(places[j])
+	})
+}
+
+// get returns cached places for the query. A query extending a cached query is also served from the cache
+// when the cached results were complete, by keeping the places whose label still matches
+func (c *autocompleteCache) get(query string, opts geocode.SearchOptions) ([]t.Place, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if entry, ok := c.entries[autocompleteKey(query, opts)]; ok && now.Before(entry.expires) {
+		return entry.places, true
+	}
+	runes := []rune(query)
+	for n := len(runes) - 1; n >= minAutocompleteLength; n-- {
+		entry, ok := c.entries[autocompleteKey(string(runes[:n]), opts)]
+		if !ok || now.After(entry.expires) || len(entry.places) >= entry.limit {
+			continue
+		}
+		var places []t.Place
+		for _, place := range entry.places {
+			if strings.Contains(strings.ToLower(place.Label), query) {
+				places = append(places, place)
+			}
+		}
+		if len(places) > 0 {
+			return places, true
+		}
+	}
+	return nil, false
+}
+
+func (c *autocompleteCache) put(query string, opts geocode.SearchOptions, places []t.Place) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	key := autocompleteKey(query, opts)
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxAutocompleteEntries {
+		var oldest string
+		var oldestExpires time.Time
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			} else if oldest == "" || entry.expires.Before(oldestExpires) {
+				oldest, oldestExpires = k, entry.expires
+			}
+		}
+		// every entry lives as long, so the one expiring first is the oldest
+		if len(c.entries) >= maxAutocompleteEntries {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = autocompleteEntry{
+		places:  places,
+		limit:   opts.Limit,
+		expires: now.Add(autocompleteTTL),
+	}
+}
+
+// autocompleteKey identifies a query and the options affecting its results, rounding the proximity location to ~1 km
+func autocompleteKey(query string, opts geocode.SearchOptions) string {
+	near := ""
+	if opts.Near != nil {
+		near = fmt.Sprintf("%.2f,%.2f", opts.Near.Latitude, opts.Near.Longitude)
+	}
+	return strings.Join([]string{query, strings.ToLower(opts.Country), opts.Lang, near, strconv.Itoa(opts.Limit)}, "|")
+}
+
+func writeAutocompleteError(w http.ResponseWriter, err error) {
+	codeErr, ok := err.(CodeError)
+	if !ok {
+		codeErr = CodeError{code: 500, msg: "Internal server error"}
+	}
+	bodyBytes, _ := json.Marshal(AutocompleteResponse{Error: codeErr.Error()})
+	w.WriteHeader(codeErr.code)
+	io.WriteString(w, string(bodyBytes[:]))
+}
+
+func writeAutocompleteResponse(w http.ResponseWriter, resp AutocompleteResponse) {
+	bodyBytes, _ := json.Marshal(resp)
+	w.WriteHeader(200)
+	io.WriteString(w, string(bodyBytes[:]))
+}
@@ -0,0 +1,39 @@
+package wipercheck
+
+import (
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/geocode"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"testing"
+	"time"
+)
+
+func TestAutocompleteCacheEvictsOldest(tt *testing.T) {
+	c := newAutocompleteCache()
+	opts := geocode.SearchOptions{Limit: defaultAutocompleteLimit}
+	places := []t.Place{{Label: "Detroit, Michigan, United States"}}
+	for i := 0; i < maxAutocompleteEntries; i++ {
+		c.put(fmt.Sprintf("query %d", i), opts, places)
+	}
+	// entries added within the same instant expire together, so making sure the first one is the oldest
+	first := c.entries[autocompleteKey("query 0", opts)]
+	first.expires = time.Now().Add(time.Second)
+	c.entries[autocompleteKey("query 0", opts)] = first
+
+	c.put("detroit", opts, places)
+	if len(c.entries) != maxAutocompleteEntries {
+		tt.Errorf("expected the cache to stay at %d entries, got %d", maxAutocompleteEntries, len(c.entries))
+	}
+	if _, ok := c.get("query 0", opts); ok {
+		tt.Error("expected the oldest entry to be evicted")
+	}
+	if _, ok := c.get("detroit", opts); !ok {
+		tt.Error("expected the new entry to be cached")
+	}
+
+	// replacing a cached query doesn't evict anything
+	c.put("query 1", opts, places)
+	if _, ok := c.get("query 2", opts); !ok || len(c.entries) != maxAutocompleteEntries {
+		tt.Errorf("expected no eviction when replacing an entry, got %d entries", len(c.entries))
+	}
+}
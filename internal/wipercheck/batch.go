@@ -0,0 +1,289 @@
+package wipercheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/geocode"
+	"github.com/evanhutnik/wipercheck-service/internal/routing"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// defaultBatchMax is the most journeys a batch can have if not configured
+	defaultBatchMax = 300
+	// defaultBatchWorkers is how many journeys of a batch are processed at once if not configured
+	defaultBatchWorkers = 8
+	// maxBatchJourneyBytes is the average size a journey of a batch can take up in the request body
+	maxBatchJourneyBytes = 4 << 10
+)
+
+type BatchRequest struct {
+	// Journeys have the same parameters as /journey, e.g. {"from": "toronto", "to": "detroit", "delay": 20}
+	Journeys []map[string]interface{} `json:"journeys"`
+}
+
+type BatchResponse struct {
+	Error    string        `json:"error,omitempty"`
+	Journeys []BatchResult `json:"journeys,omitempty"`
+}
+
+// BatchResult is the response to a journey of a batch, with the status code it would have been returned with
+type BatchResult struct {
+	Status int `json:"status"`
+	*JourneyResponse
+}
+
+// BatchHandler is the handler for the POST /journeys endpoint, processing many journeys at once
+func (s *Service) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeBatchResponse(w, 405, &BatchResponse{Error: "/journeys only supports POST requests"})
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, int64(s.batchMax)*maxBatchJourneyBytes)
+	batchReq, err := decodeBatch(body, s.batchMax)
+	if err != nil {
+		writeBatchResponse(w, 400, &BatchResponse{Error: err.Error()})
+		return
+	}
+	writeBatchResponse(w, 200, s.Batch(r.Context(), *batchReq))
+}
+
+// decodeBatch decodes the journeys of the request body one at a time, stopping as soon as there are more than the maximum
+func decodeBatch(body io.Reader, max int) (*BatchRequest, error) {
+	countErr := fmt.Errorf("Between 1 and %v journeys are required", max)
+	dec := json.NewDecoder(body)
+	batchReq := &BatchRequest{}
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid request body: %v", err.Error())
+		}
+		if key != "journeys" {
+			var ignored json.RawMessage
+			if err = dec.Decode(&ignored); err != nil {
+				return nil, fmt.Errorf("Invalid request body: %v", err.Error())
+			}
+			continue
+		}
+		if err = expectDelim(dec, '['); err != nil {
+			return nil, err
+		}
+		for dec.More() {
+			if len(batchReq.Journeys) == max {
+				return nil, countErr
+			}
+			var journey map[string]interface{}
+			if err = dec.Decode(&journey); err != nil {
+				return nil, fmt.Errorf("Invalid request body: %v", err.Error())
+			}
+			batchReq.Journeys = append(batchReq.Journeys, journey)
+		}
+		if err = expectDelim(dec, ']'); err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	if len(batchReq.Journeys) == 0 {
+		return nil, countErr
+	}
+	return batchReq, nil
+}
+
+// expectDelim reads the next token of the body, which must be the delimiter
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("Invalid request body: %v", err.Error())
+	} else if token != delim {
+		return fmt.Errorf("Invalid request body: expected '%v' but found '%v'", delim, token)
+	}
+	return nil
+}
+
+// Batch processes the journeys with a pool of workers, sharing geocoding, routes and forecasts between them
+func (s *Service) Batch(ctx context.Context, batchReq BatchRequest) *BatchResponse {
+	// the batch runs on a copy of the service whose upstream calls are cached for the duration of the batch
+	batch := *s
+	batch.gc = &memoGeocoder{Geocoder: s.gc, memo: newMemo()}
+	batch.router = &memoRouter{Router: s.router, memo: newMemo()}
+	batch.weatherMemo = newMemo()
+
+	resp := &BatchResponse{
+		Journeys: make([]BatchResult, len(batchReq.Journeys)),
+	}
+	indexes := make(chan int)
+	wg := new(sync.WaitGroup)
+	for w := 0; w < s.batchWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				resp.Journeys[i] = batch.batchJourney(ctx, batchReq.Journeys[i])
+			}
+		}()
+	}
+	for i := range batchReq.Journeys {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return resp
+}
+
+// batchJourney processes a journey of a batch as if its parameters had been sent to /journey
+func (s *Service) batchJourney(ctx context.Context, spec map[string]interface{}) BatchResult {
+	values, err := specValues(spec)
+	if err != nil {
+		return BatchResult{Status: 400, JourneyResponse: &JourneyResponse{Error: err.Error()}}
+	}
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/journey?"+values.Encode(), nil)
+	journey, err := s.Journey(ctx, r)
+	if err != nil {
+		code, errResp := errorResponse(err)
+		if errResp == nil {
+			errResp = &JourneyResponse{Error: "Internal server error"}
+		}
+		return BatchResult{Status: code, JourneyResponse: errResp}
+	}
+	return BatchResult{Status: 200, JourneyResponse: journey}
+}
+
+// specValues converts a journey's parameters to query parameters
+func specValues(spec map[string]interface{}) (url.Values, error) {
+	values := url.Values{}
+	for key, value := range spec {
+		switch v := value.(type) {
+		case string:
+			values.Set(key, v)
+		case float64:
+			values.Set(key, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			values.Set(key, strconv.FormatBool(v))
+		default:
+			return nil, errors.New(fmt.Sprintf("Invalid value for '%v', must be a string, number or boolean", key))
+		}
+	}
+	return values, nil
+}
+
+// memo caches the results of calls by key, with concurrent calls for the same key waiting for the first
+type memo struct {
+	mu    sync.Mutex
+	calls map[string]*memoCall
+}
+
+type memoCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func newMemo() *memo {
+	return &memo{calls: make(map[string]*memoCall)}
+}
+
+// do returns the result of the call for the key, calling fn if it hasn't succeeded yet.
+// Failures are only shared with the calls waiting on them, so later calls try again
+func (m *memo) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	m.mu.Lock()
+	call, ok := m.calls[key]
+	if !ok {
+		call = &memoCall{done: make(chan struct{})}
+		m.calls[key] = call
+	}
+	m.mu.Unlock()
+
+	if ok {
+		<-call.done
+		return call.value, call.err
+	}
+	call.value, call.err = fn()
+	if call.err != nil {
+		m.mu.Lock()
+		delete(m.calls, key)
+		m.mu.Unlock()
+	}
+	close(call.done)
+	return call.value, call.err
+}
+
+// memoGeocoder caches the geocoder's results
+type memoGeocoder struct {
+	geocode.Geocoder
+	memo *memo
+}
+
+func (g *memoGeocoder) ReverseGeoCode(ctx context.Context, coords t.Coordinates, lang string) (*t.Location, error) {
+	value, err := g.memo.do(fmt.Sprintf("reverse|%v,%v|%v", coords.Latitude, coords.Longitude, lang), func() (interface{}, error) {
+		return g.Geocoder.ReverseGeoCode(ctx, coords, lang)
+	})
+	return value.(*t.Location), err
+}
+
+func (g *memoGeocoder) Search(ctx context.Context, query string, opts geocode.SearchOptions) ([]t.Place, error) {
+	key := fmt.Sprintf("search|%v|%v|%v|%v|%v|%v", query, opts.Limit, opts.Country, opts.Region, opts.Lang, opts.Autocomplete)
+	if opts.Near != nil {
+		key += fmt.Sprintf("|%v,%v", opts.Near.Latitude, opts.Near.Longitude)
+	}
+	value, err := g.memo.do(key, func() (interface{}, error) {
+		return g.Geocoder.Search(ctx, query, opts)
+	})
+	return value.([]t.Place), err
+}
+
+// TimeZone keeps the geocoder usable as a TimeZoner, returning an empty string if it isn't one
+func (g *memoGeocoder) TimeZone(coords t.Coordinates) string {
+	if tz, ok := g.Geocoder.(geocode.TimeZoner); ok {
+		return tz.TimeZone(coords)
+	}
+	return ""
+}
+
+// NearestLocality keeps the geocoder usable as a LocalityFinder, returning nil if it isn't one
+func (g *memoGeocoder) NearestLocality(ctx context.Context, coords t.Coordinates, minPopulation int64, lang string) (*t.Place, error) {
+	finder, ok := g.Geocoder.(geocode.LocalityFinder)
+	if !ok {
+		return nil, nil
+	}
+	value, err := g.memo.do(fmt.Sprintf("locality|%v,%v|%v|%v", coords.Latitude, coords.Longitude, minPopulation, lang), func() (interface{}, error) {
+		return finder.NearestLocality(ctx, coords, minPopulation, lang)
+	})
+	return value.(*t.Place), err
+}
+
+// memoRouter caches the router's routes
+type memoRouter struct {
+	routing.Router
+	memo *memo
+}
+
+func (r *memoRouter) Route(ctx context.Context, trip *t.Trip, opts routing.Options) (*t.Route, error) {
+	key := fmt.Sprintf("%v,%v|%v,%v|%v|%v", trip.From.Latitude, trip.From.Longitude, trip.To.Latitude, trip.To.Longitude,
+		opts.Vehicle, strings.Join(opts.Avoid, ","))
+	for _, via := range opts.Via {
+		key += fmt.Sprintf("|%v,%v", via.Latitude, via.Longitude)
+	}
+	value, err := r.memo.do(key, func() (interface{}, error) {
+		return r.Router.Route(ctx, trip, opts)
+	})
+	return value.(*t.Route), err
+}
+
+func writeBatchResponse(w http.ResponseWriter, code int, resp *BatchResponse) {
+	bodyBytes, _ := json.Marshal(resp)
+	w.WriteHeader(code)
+	io.WriteString(w, string(bodyBytes[:]))
+}
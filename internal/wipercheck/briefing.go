@@ -0,0 +1,161 @@
+package wipercheck
+
+import (
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/i18n"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"math"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// wet steps at or above this precipitation chance are described as expected rather than possible
+const likelyPop = 0.6
+
+// briefingSegment is a run of consecutive steps sharing the same conditions in the briefing
+type briefingSegment struct {
+	wet    bool
+	likely bool
+	kind   string
+	label  string
+	start  int
+	end    int
+}
+
+// briefing generates a short narrative paragraph describing the weather along the trip
+func briefing(steps []t.Step, lang string) string {
+	if len(steps) == 0 {
+		return ""
+	}
+	segments := briefingSegments(steps, lang)
+
+	var builder strings.Builder
+	for i, seg := range segments {
+		first := steps[seg.start]
+		var clause string
+		switch {
+		case i == 0 && !seg.wet && len(segments) == 1:
+			clause = i18n.Sprintf(lang, "briefing.dry.whole")
+		case i == 0 && !seg.wet:
+			clause = i18n.Sprintf(lang, "briefing.dry.until", stepPlace(steps[segments[1].start]))
+		case i == 0 && seg.likely && len(segments) == 1:
+			clause = i18n.Sprintf(lang, "briefing.wet.whole", seg.label)
+		case i == 0 && seg.likely:
+			clause = i18n.Sprintf(lang, "briefing.wet.duration", seg.label, segmentDuration(steps, seg, lang))
+		case i == 0:
+			clause = i18n.Sprintf(lang, "briefing.possible.start", i18n.Capitalize(seg.label))
+		case !seg.wet:
+			clause = i18n.Sprintf(lang, "briefing.then.dry", stepPlace(first))
+		case seg.likely && seg.end == len(steps)-1:
+			clause = i18n.Sprintf(lang, "briefing.then.rest", seg.label)
+		case seg.likely:
+			clause = i18n.Sprintf(lang, "briefing.then.duration", seg.label, segmentDuration(steps, seg, lang))
+		default:
+			clause = i18n.Sprintf(lang, "briefing.possible.after", seg.label, stepPlace(first), localTime(first, lang))
+		}
+		if i > 0 {
+			if seg.wet && !seg.likely {
+				builder.WriteString("; ")
+			} else {
+				builder.WriteString(", ")
+			}
+		}
+		builder.WriteString(clause)
+	}
+	builder.WriteString(".")
+	return builder.String()
+}
+
+// briefingSegments groups consecutive steps with the same kind of conditions together
+func briefingSegments(steps []t.Step, lang string) []briefingSegment {
+	var segments []briefingSegment
+	for i, step := range steps {
+		kind := precipKind(step.Weather.Conditions)
+		wet := isWet(step.Weather)
+		likely := wet && step.Weather.Pop >= likelyPop
+		if len(segments) > 0 {
+			last := &segments[len(segments)-1]
+			if last.wet == wet && last.likely == likely && last.kind == kind {
+				last.end = i
+				// describing the segment by its wettest step
+				if wet && step.Weather.Pop > steps[last.start].Weather.Pop {
+					last.label = conditionsLabel(step.Weather.Conditions, lang)
+				}
+				continue
+			}
+		}
+		segments = append(segments, briefingSegment{
+			wet:    wet,
+			likely: likely,
+			kind:   kind,
+			label:  conditionsLabel(step.Weather.Conditions, lang),
+			start:  i,
+			end:    i,
+		})
+	}
+	return segments
+}
+
+// conditionsLabel returns the lowercase description of the conditions, falling back to a generic term
+func conditionsLabel(c t.Conditions, lang string) string {
+	if precipKind(c) == kindNone || c.Description == "" {
+		return i18n.Sprintf(lang, "precipitation")
+	}
+	return strings.ToLower(c.Description)
+}
+
+// stepPlace returns the name of the place a step is in, falling back to the road name
+func stepPlace(step t.Step) string {
+	if step.Location != nil && step.Location.Locality != "" {
+		return step.Location.Locality
+	}
+	return step.Name
+}
+
+// segmentDuration returns a rounded human-readable duration of the segment
+func segmentDuration(steps []t.Step, seg briefingSegment, lang string) string {
+	end := steps[seg.end].TotalDuration
+	if seg.end+1 < len(steps) {
+		end = steps[seg.end+1].TotalDuration
+	}
+	// rounding to the nearest 5 minutes
+	minutes := int(math.Max(5, math.Round((end-steps[seg.start].TotalDuration)/300)*5))
+	hours, minutes := minutes/60, minutes%60
+	switch {
+	case hours == 0:
+		return i18n.Sprintf(lang, "duration.minutes", minutes)
+	case minutes == 0:
+		return pluralHours(hours, lang)
+	default:
+		return i18n.Sprintf(lang, "duration.hoursMinutes", pluralHours(hours, lang), minutes)
+	}
+}
+
+func pluralHours(hours int, lang string) string {
+	if hours == 1 {
+		return i18n.Sprintf(lang, "duration.hour")
+	}
+	return i18n.Sprintf(lang, "duration.hours", hours)
+}
+
+// localTime returns the step's ETA in the local time of the step, rounded to the nearest 15 minutes
+func localTime(step t.Step, lang string) string {
+	eta := time.Unix(step.Arrival, 0).Round(15 * time.Minute).In(stepLocation(step))
+	if eta.Minute() == 0 {
+		return eta.Format(i18n.Sprintf(lang, "time.hour"))
+	}
+	return eta.Format(i18n.Sprintf(lang, "time.hourMinute"))
+}
+
+// stepLocation returns the time zone of a step, estimating it from the longitude if the weather provider did not supply it
+func stepLocation(step t.Step) *time.Location {
+	if step.Weather != nil && step.Weather.Timezone != "" {
+		loc, err := time.LoadLocation(step.Weather.Timezone)
+		if err == nil {
+			return loc
+		}
+	}
+	offset := int(math.Round(step.Coordinates.Longitude / 15))
+	return time.FixedZone(fmt.Sprintf("UTC%+d", offset), offset*3600)
+}
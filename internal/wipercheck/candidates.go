@@ -0,0 +1,79 @@
+package wipercheck
+
+import (
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/geocode"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"strings"
+)
+
+const (
+	// maxCandidates is the largest number of candidates that can be requested for an ambiguous address
+	maxCandidates = 10
+	// minConfidence is the confidence below which the best geocoding match is considered ambiguous
+	minConfidence = 0.6
+	// closeConfidence is how close the second best match must be to the best for the address to be considered ambiguous
+	closeConfidence = 0.1
+)
+
+// AmbiguousError is returned when an address matches several places without a clear best match
+type AmbiguousError struct {
+	candidates []t.Place
+	// byParam holds the candidates by request parameter, e.g. 'from'
+	byParam map[string][]t.Place
+}
+
+func (a AmbiguousError) Error() string {
+	var params []string
+	for _, param := range []string{"from", "to"} {
+		if _, ok := a.byParam[param]; ok {
+			params = append(params, fmt.Sprintf("'%v'", param))
+		}
+	}
+	return fmt.Sprintf("Ambiguous %v address. Choose one of the candidates, or narrow the search with the 'region' or 'country' parameters.",
+		strings.Join(params, " and "))
+}
+
+// searchOptions returns the geocoding options of the request
+func (req *JourneyRequest) searchOptions() geocode.SearchOptions {
+	return geocode.SearchOptions{
+		Country: req.country,
+		Region:  req.region,
+		Lang:    req.lang,
+	}
+}
+
+// ambiguous returns whether the best place has a low confidence or other places score closely to it
+func ambiguous(places []t.Place) bool {
+	if len(places) < 2 {
+		return false
+	}
+	return places[0].Confidence < minConfidence || places[0].Confidence-places[1].Confidence < closeConfidence
+}
+
+// asAmbiguous returns the error as an AmbiguousError for the request parameter, or nil if it isn't one
+func asAmbiguous(err error, param string) *AmbiguousError {
+	ambiguousErr, ok := err.(*AmbiguousError)
+	if !ok {
+		return nil
+	}
+	ambiguousErr.byParam = map[string][]t.Place{param: ambiguousErr.candidates}
+	return ambiguousErr
+}
+
+// mergeAmbiguous combines the candidates of ambiguous errors, returning nil if there are none
+func mergeAmbiguous(errs ...*AmbiguousError) *AmbiguousError {
+	var merged *AmbiguousError
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &AmbiguousError{byParam: make(map[string][]t.Place)}
+		}
+		for param, candidates := range err.byParam {
+			merged.byParam[param] = candidates
+		}
+	}
+	return merged
+}
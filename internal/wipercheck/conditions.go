@@ -0,0 +1,78 @@
+package wipercheck
+
+import t "github.com/evanhutnik/wipercheck-service/internal/types"
+
+// precipitation kinds derived from OpenWeather condition ids
+// https://openweathermap.org/weather-conditions#Weather-Condition-Codes-2
+const (
+	kindNone         = ""
+	kindThunderstorm = "thunderstorm"
+	kindDrizzle      = "drizzle"
+	kindRain         = "rain"
+	kindFreezing     = "freezing"
+	kindSnow         = "snow"
+)
+
+// precipKind returns the kind of precipitation described by the conditions, or kindNone if it is not precipitating
+func precipKind(c t.Conditions) string {
+	switch {
+	case c.Id >= 200 && c.Id < 300:
+		return kindThunderstorm
+	case c.Id >= 300 && c.Id < 400:
+		return kindDrizzle
+	case c.Id == 511:
+		return kindFreezing
+	case c.Id >= 500 && c.Id < 600:
+		return kindRain
+	case c.Id >= 611 && c.Id <= 616:
+		return kindFreezing
+	case c.Id >= 600 && c.Id < 700:
+		return kindSnow
+	default:
+		return kindNone
+	}
+}
+
+// steps with a precipitation chance at or above this value are considered wet even if the conditions are not
+const wetPop = 0.5
+
+// isWet returns whether precipitation is expected or likely for the weather
+func isWet(w *t.Weather) bool {
+	return precipKind(w.Conditions) != kindNone || w.Pop >= wetPop
+}
+
+// severity returns a multiplier for how much the kind of precipitation affects driving
+func severity(kind string) float64 {
+	switch kind {
+	case kindDrizzle:
+		return 0.5
+	case kindThunderstorm:
+		return 1.5
+	case kindFreezing, kindSnow:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// weatherTypes returns the types used to filter on the weather, e.g. "snow" or "dry"
+func weatherTypes(w *t.Weather) []string {
+	var types []string
+	kind := precipKind(w.Conditions)
+	switch {
+	case kind != kindNone:
+		types = append(types, kind)
+	case w.Conditions.Id >= 700 && w.Conditions.Id < 800:
+		types = append(types, "fog")
+	case w.Conditions.Id == 800:
+		types = append(types, "clear")
+	case w.Conditions.Id > 800:
+		types = append(types, "clouds")
+	}
+	if isWet(w) {
+		types = append(types, "wet")
+	} else {
+		types = append(types, "dry")
+	}
+	return types
+}
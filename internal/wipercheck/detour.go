@@ -0,0 +1,143 @@
+package wipercheck
+
+import (
+	"context"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"math"
+	"sync"
+)
+
+// detourOffsets are the distances in km either side of a hazardous segment that detours are routed through
+var detourOffsets = []float64{30, 60}
+
+// defaultDetourPenalty is how many minutes of hazardous driving each extra minute of a detour counts as if not configured
+const defaultDetourPenalty = 1.0
+
+// kmPerDegree is the approximate length of a degree of latitude
+const kmPerDegree = 111.2
+
+type Detour struct {
+	Via                  t.Coordinates  `json:"via"`
+	ExtraMinutes         float64        `json:"extraMinutes"`
+	HazardMinutesAvoided float64        `json:"hazardMinutesAvoided"`
+	TripSummary          *t.TripSummary `json:"tripSummary"`
+	Steps                []t.Step       `json:"detailedSteps"`
+}
+
+// detour tries routing through points offset from the trip's longest hazardous segment, returning the detour
+// with the lowest cost if it is worth the extra time, or nil if there is none.
+// The cost of a route is its hazardous minutes plus its extra minutes weighted by the detour penalty
+func (s *Service) detour(ctx context.Context, trip *t.Trip, route *t.Route, summary *t.TripSummary, steps []t.Step, departure int64, req *JourneyRequest) *Detour {
+	start, end, ok := hazardSegment(steps)
+	if !ok {
+		return nil
+	}
+	candidates := viaCandidates(steps, start, end)
+
+	detours := make([]*Detour, len(candidates))
+	wg := new(sync.WaitGroup)
+	wg.Add(len(candidates))
+	for i, via := range candidates {
+		i, via := i, via
+		go func() {
+			defer wg.Done()
+			opts := req.routeOptions()
+			opts.Via = []t.Coordinates{via}
+			detourRoute, err := s.router.Route(ctx, trip, opts)
+			if err != nil {
+				s.Logger.Warnf("Error routing detour through (%v,%v): %v", via.Latitude, via.Longitude, err.Error())
+				return
+			}
+			detourSteps := s.weather(ctx, detourRoute, departure, req.lang)
+			markHazards(detourSteps, req.vehicle, req.lang)
+			detourSummary := s.tripSummary(detourRoute, detourSteps)
+			detours[i] = &Detour{
+				Via:                  via,
+				ExtraMinutes:         math.Round((detourRoute.Duration - route.Duration) / 60),
+				HazardMinutesAvoided: summary.HazardMinutes - detourSummary.HazardMinutes,
+				TripSummary:          detourSummary,
+				Steps:                detourSteps,
+			}
+		}()
+	}
+	wg.Wait()
+
+	var best *Detour
+	var bestCost float64
+	for _, detour := range detours {
+		if detour == nil || detour.HazardMinutesAvoided <= 0 {
+			continue
+		}
+		cost := detour.TripSummary.HazardMinutes + math.Max(0, detour.ExtraMinutes)*s.detourPenalty
+		if cost < summary.HazardMinutes && (best == nil || cost < bestCost) {
+			best, bestCost = detour, cost
+		}
+	}
+	// only the chosen detour's steps are returned, filtered and located like the trip's steps
+	if best != nil {
+		best.Steps = req.filter.steps(best.Steps)
+		s.locateSteps(ctx, best.Steps, req.lang)
+	}
+	return best
+}
+
+// hazardSegment returns the indexes of the first and last steps of the longest run of steps with hazards
+func hazardSegment(steps []t.Step) (int, int, bool) {
+	start, end, longest := 0, 0, 0.0
+	found := false
+	for i := 0; i < len(steps); i++ {
+		if len(steps[i].Hazards) == 0 {
+			continue
+		}
+		j := i
+		for j+1 < len(steps) && len(steps[j+1].Hazards) > 0 {
+			j++
+		}
+		// runs are measured up to the next step, as each step's weather covers the trip until then
+		duration := steps[j].TotalDuration - steps[i].TotalDuration
+		if j+1 < len(steps) {
+			duration = steps[j+1].TotalDuration - steps[i].TotalDuration
+		}
+		if !found || duration > longest {
+			start, end, longest, found = i, j, duration, true
+		}
+		i = j
+	}
+	return start, end, found
+}
+
+// viaCandidates returns points offset perpendicular to the direction of travel on either side of the segment's midpoint
+func viaCandidates(steps []t.Step, start int, end int) []t.Coordinates {
+	from, to := steps[0].Coordinates, steps[len(steps)-1].Coordinates
+	if start > 0 {
+		from = steps[start-1].Coordinates
+	}
+	if end+1 < len(steps) {
+		to = steps[end+1].Coordinates
+	}
+	mid := t.Coordinates{
+		Latitude:  (steps[start].Coordinates.Latitude + steps[end].Coordinates.Latitude) / 2,
+		Longitude: (steps[start].Coordinates.Longitude + steps[end].Coordinates.Longitude) / 2,
+	}
+
+	// working in km on a local flat projection, which is accurate enough at these distances
+	cosLat := math.Cos(mid.Latitude * math.Pi / 180)
+	dy := (to.Latitude - from.Latitude) * kmPerDegree
+	dx := (to.Longitude - from.Longitude) * kmPerDegree * cosLat
+	length := math.Hypot(dx, dy)
+	if length == 0 || cosLat == 0 {
+		return nil
+	}
+	perpX, perpY := -dy/length, dx/length
+
+	var candidates []t.Coordinates
+	for _, offset := range detourOffsets {
+		for _, side := range []float64{1, -1} {
+			candidates = append(candidates, t.Coordinates{
+				Latitude:  mid.Latitude + side*offset*perpY/kmPerDegree,
+				Longitude: mid.Longitude + side*offset*perpX/(kmPerDegree*cosLat),
+			})
+		}
+	}
+	return candidates
+}
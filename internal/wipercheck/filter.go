@@ -0,0 +1,148 @@
+package wipercheck
+
+import (
+	"fmt"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"strconv"
+	"strings"
+)
+
+// filter operators, ordered so that two-character operators are matched first
+var filterOps = []string{">=", "<=", "!=", ">", "<", "="}
+
+// numericFields are the filterable numeric weather fields, in the units returned in the response
+var numericFields = map[string]func(w *t.Weather) float64{
+	"pop":  func(w *t.Weather) float64 { return w.Pop * 100 },
+	"temp": func(w *t.Weather) float64 { return w.Temp },
+	"wind": func(w *t.Weather) float64 { return w.WindSpeed },
+	"gust": func(w *t.Weather) float64 { return w.WindGust },
+}
+
+// stepFilter is a parsed filter expression, matching steps that satisfy all of its clauses
+type stepFilter []filterClause
+
+// filterClause is a single comparison in a filter expression, matching if any of its values match
+type filterClause struct {
+	field   string
+	op      string
+	values  []string
+	numbers []float64
+}
+
+// parseFilter parses a filter expression such as 'pop>=30,type=snow|freezing,wind>50'
+func parseFilter(expr string) (stepFilter, error) {
+	var filter stepFilter
+	for _, raw := range strings.Split(expr, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		clause, err := parseFilterClause(raw)
+		if err != nil {
+			return nil, CodeError{code: 400, msg: fmt.Sprintf("Invalid filter clause '%v': %v", raw, err.Error())}
+		}
+		filter = append(filter, *clause)
+	}
+	return filter, nil
+}
+
+func parseFilterClause(raw string) (*filterClause, error) {
+	clause := &filterClause{}
+	for _, op := range filterOps {
+		if i := strings.Index(raw, op); i > 0 {
+			clause.field = strings.ToLower(strings.TrimSpace(raw[:i]))
+			clause.op = op
+			for _, value := range strings.Split(raw[i+len(op):], "|") {
+				clause.values = append(clause.values, strings.ToLower(strings.TrimSpace(value)))
+			}
+			break
+		}
+	}
+	if clause.op == "" {
+		return nil, fmt.Errorf("expected a comparison such as 'pop>=30'")
+	}
+
+	if clause.field == "type" {
+		if clause.op != "=" && clause.op != "!=" {
+			return nil, fmt.Errorf("'type' only supports '=' and '!='")
+		}
+		return clause, nil
+	}
+	if _, ok := numericFields[clause.field]; !ok {
+		return nil, fmt.Errorf("unknown field '%v', expected one of pop, type, temp, wind or gust", clause.field)
+	}
+	for _, value := range clause.values {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%v' is not a number", value)
+		}
+		clause.numbers = append(clause.numbers, number)
+	}
+	return clause, nil
+}
+
+// matches returns whether the weather satisfies every clause of the filter
+func (f stepFilter) matches(w *t.Weather) bool {
+	for _, clause := range f {
+		if !clause.matches(w) {
+			return false
+		}
+	}
+	return true
+}
+
+// steps returns the steps whose weather matches the filter
+func (f stepFilter) steps(steps []t.Step) []t.Step {
+	var matching []t.Step
+	for _, step := range steps {
+		if f.matches(step.Weather) {
+			matching = append(matching, step)
+		}
+	}
+	return matching
+}
+
+func (c filterClause) matches(w *t.Weather) bool {
+	if c.field == "type" {
+		matched := false
+		for _, weatherType := range weatherTypes(w) {
+			for _, value := range c.values {
+				if weatherType == value {
+					matched = true
+				}
+			}
+		}
+		return matched == (c.op == "=")
+	}
+
+	actual := numericFields[c.field](w)
+	if c.op == "!=" {
+		for _, number := range c.numbers {
+			if actual == number {
+				return false
+			}
+		}
+		return true
+	}
+	for _, number := range c.numbers {
+		if compare(actual, c.op, number) {
+			return true
+		}
+	}
+	return false
+}
+
+func compare(actual float64, op string, expected float64) bool {
+	switch op {
+	case ">=":
+		return actual >= expected
+	case "<=":
+		return actual <= expected
+	case ">":
+		return actual > expected
+	case "<":
+		return actual < expected
+	default:
+		return actual == expected
+	}
+}
@@ -0,0 +1,98 @@
+package wipercheck
+
+import (
+	"github.com/evanhutnik/wipercheck-service/internal/i18n"
+	"github.com/evanhutnik/wipercheck-service/internal/routing"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"math"
+)
+
+// hazardThresholds are the weather conditions that are hazardous for a vehicle
+type hazardThresholds struct {
+	// kinds of precipitation that are hazardous whenever they're forecast
+	kinds []string
+	// pop is the chance of precipitation at which any precipitation is hazardous
+	pop float64
+	// wind and gust are the sustained wind speed and gust speeds in km/h at which wind is hazardous
+	wind float64
+	gust float64
+}
+
+// hazardProfiles are the hazard thresholds for each vehicle. Motorcyclists and cyclists are affected by any rain,
+// while high-sided trucks are affected by weaker gusts than cars
+var hazardProfiles = map[string]hazardThresholds{
+	routing.VehicleCar: {
+		kinds: []string{kindThunderstorm, kindFreezing, kindSnow},
+		pop:   0.8,
+		wind:  65,
+		gust:  90,
+	},
+	routing.VehicleTruck: {
+		kinds: []string{kindThunderstorm, kindFreezing, kindSnow},
+		pop:   0.8,
+		wind:  45,
+		gust:  65,
+	},
+	routing.VehicleMotorcycle: {
+		kinds: []string{kindThunderstorm, kindDrizzle, kindRain, kindFreezing, kindSnow},
+		pop:   0.3,
+		wind:  40,
+		gust:  60,
+	},
+	routing.VehicleBicycle: {
+		kinds: []string{kindThunderstorm, kindRain, kindFreezing, kindSnow},
+		pop:   0.3,
+		wind:  30,
+		gust:  50,
+	},
+	routing.VehicleFoot: {
+		kinds: []string{kindThunderstorm, kindFreezing},
+		pop:   0.6,
+		wind:  50,
+		gust:  75,
+	},
+}
+
+// markHazards sets the localized reasons that each step's weather is hazardous for the vehicle.
+// Steps on a ferry are skipped as the weather doesn't affect driving on them
+func markHazards(steps []t.Step, vehicle string, lang string) {
+	thresholds := hazardProfiles[vehicle]
+	for i := range steps {
+		if steps[i].Mode == routing.ModeFerry {
+			continue
+		}
+		steps[i].Hazards = thresholds.reasons(steps[i].Weather, lang)
+	}
+}
+
+// reasons returns why the weather is hazardous, or nil if it is not
+func (h hazardThresholds) reasons(w *t.Weather, lang string) []string {
+	if w == nil {
+		return nil
+	}
+	var reasons []string
+	kind := precipKind(w.Conditions)
+	switch {
+	case kind != kindNone && contains(h.kinds, kind):
+		reasons = append(reasons, i18n.Sprintf(lang, "hazard.conditions", conditionsLabel(w.Conditions, lang)))
+	case w.Pop >= h.pop:
+		reasons = append(reasons, i18n.Sprintf(lang, "hazard.pop", int(math.Round(w.Pop*100))))
+	}
+	if h.wind > 0 && w.WindSpeed >= h.wind {
+		reasons = append(reasons, i18n.Sprintf(lang, "hazard.wind", math.Round(w.WindSpeed)))
+	}
+	if h.gust > 0 && w.WindGust >= h.gust {
+		reasons = append(reasons, i18n.Sprintf(lang, "hazard.gust", math.Round(w.WindGust)))
+	}
+	return reasons
+}
+
+// contains returns whether the value is in the list
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package wipercheck
+
+import (
+	"context"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/geocode"
+	"github.com/evanhutnik/wipercheck-service/internal/pluscode"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// decimal coordinates such as '42.33,-83.04' or '42.33 -83.04'
+	decimalPattern = regexp.MustCompile(`^([-+]?\d{1,3}(?:\.\d+)?)\s*[,\s]\s*([-+]?\d{1,3}(?:\.\d+)?)$`)
+	// a single DMS coordinate such as 42°19'47"N, with optional minutes and seconds
+	dmsPattern = regexp.MustCompile(`(\d{1,3}(?:\.\d+)?)\s*°\s*(?:(\d{1,2}(?:\.\d+)?)\s*['′]\s*)?(?:(\d{1,2}(?:\.\d+)?)\s*(?:"|″|'')\s*)?([NSEWnsew])`)
+	// a plus code, optionally followed by a locality to recover short codes from
+	plusCodePattern = regexp.MustCompile(`^([23456789CFGHJMPQRVWXcfghjmpqrvwx0]{2,8}\+[23456789CFGHJMPQRVWXcfghjmpqrvwx]*)(?:[\s,]+(.+))?$`)
+)
+
+// parseLocation returns the coordinates of a 'from' or 'to' input that doesn't need geocoding: a saved place,
+// decimal or DMS coordinates, or a plus code. It returns nil if the input should be geocoded as an address.
+func (s *Service) parseLocation(ctx context.Context, input string, opts geocode.SearchOptions) (*t.Coordinates, error) {
+	input = strings.TrimSpace(input)
+	if coords, ok := s.places.Lookup(input); ok {
+		return coords, nil
+	}
+	if match := decimalMatch(input); match != nil {
+		return decimalCoordinates(input, match)
+	}
+	if matches := dmsPattern.FindAllStringSubmatch(input, -1); len(matches) > 0 {
+		return dmsCoordinates(input, matches)
+	}
+	if match := plusCodePattern.FindStringSubmatch(input); match != nil && pluscode.IsValid(match[1]) {
+		return s.plusCodeCoordinates(ctx, input, match[1], match[2], opts)
+	}
+	return nil, nil
+}
+
+// decimalMatch matches decimal coordinates that are separated by a comma or have a decimal point, so that inputs
+// such as '42 83' are geocoded as addresses rather than read as coordinates
+func decimalMatch(input string) []string {
+	match := decimalPattern.FindStringSubmatch(input)
+	if match == nil || (!strings.Contains(input, ",") && !strings.Contains(match[1]+match[2], ".")) {
+		return nil
+	}
+	return match
+}
+
+func decimalCoordinates(input string, match []string) (*t.Coordinates, error) {
+	lat, _ := strconv.ParseFloat(match[1], 64)
+	lon, _ := strconv.ParseFloat(match[2], 64)
+	switch {
+	case math.Abs(lat) > 90 && math.Abs(lon) <= 90:
+		return nil, CodeError{code: 400, msg: fmt.Sprintf("'%v' was read as coordinates, but %v is not a valid latitude. "+
+			"Coordinates must be given as latitude,longitude, e.g. '%v,%v'.", input, match[1], match[2], match[1])}
+	case math.Abs(lat) > 90 || math.Abs(lon) > 180:
+		return nil, CodeError{code: 400, msg: fmt.Sprintf("'%v' was read as coordinates, but they are out of range. "+
+			"Latitude must be between -90 and 90 and longitude between -180 and 180.", input)}
+	}
+	return &t.Coordinates{Latitude: lat, Longitude: lon}, nil
+}
+
+func dmsCoordinates(input string, matches [][]string) (*t.Coordinates, error) {
+	if len(matches) != 2 {
+		return nil, CodeError{code: 400, msg: fmt.Sprintf("'%v' was read as DMS coordinates, but %d coordinate(s) were found. "+
+			"Both a latitude and a longitude are needed, e.g. 42°19'47\"N 83°2'45\"W.", input, len(matches))}
+	}
+	coords := &t.Coordinates{}
+	var hasLat, hasLon bool
+	for _, match := range matches {
+		degrees, _ := strconv.ParseFloat(match[1], 64)
+		minutes, _ := strconv.ParseFloat(match[2], 64)
+		seconds, _ := strconv.ParseFloat(match[3], 64)
+		value := degrees + minutes/60 + seconds/3600
+		switch strings.ToUpper(match[4]) {
+		case "S":
+			value = -value
+			fallthrough
+		case "N":
+			coords.Latitude, hasLat = value, true
+		case "W":
+			value = -value
+			fallthrough
+		case "E":
+			coords.Longitude, hasLon = value, true
+		}
+	}
+	if !hasLat || !hasLon {
+		axis := "latitudes"
+		if !hasLat {
+			axis = "longitudes"
+		}
+		return nil, CodeError{code: 400, msg: fmt.Sprintf("'%v' was read as DMS coordinates, but both are %v. "+
+			"One coordinate must be N or S and the other E or W.", input, axis)}
+	}
+	if math.Abs(coords.Latitude) > 90 || math.Abs(coords.Longitude) > 180 {
+		return nil, CodeError{code: 400, msg: fmt.Sprintf("'%v' was read as DMS coordinates, but they are out of range.", input)}
+	}
+	return coords, nil
+}
+
+// plusCodeCoordinates decodes a plus code, using the geocoded locality as the reference for short codes
+func (s *Service) plusCodeCoordinates(ctx context.Context, input string, code string, locality string, opts geocode.SearchOptions) (*t.Coordinates, error) {
+	if pluscode.IsShort(code) {
+		if locality == "" {
+			return nil, CodeError{code: 400, msg: fmt.Sprintf("'%v' was read as a short plus code, which needs a locality to be decoded. "+
+				"Add a town or city, e.g. '%v Detroit', or use the full code.", input, code)}
+		}
+		ref, err := s.geoCode(ctx, locality, opts, 0)
+		if err != nil {
+			return nil, err
+		}
+		if code, err = pluscode.RecoverNearest(code, ref.Latitude, ref.Longitude); err != nil {
+			return nil, CodeError{code: 400, msg: fmt.Sprintf("'%v' was read as a plus code, but it could not be decoded: %v", input, err.Error())}
+		}
+	} else if locality != "" {
+		return nil, CodeError{code: 400, msg: fmt.Sprintf("'%v' was read as a full plus code followed by '%v'. "+
+			"Full plus codes don't need a locality, so remove it or use a short code.", input, locality)}
+	}
+
+	area, err := pluscode.Decode(code)
+	if err != nil {
+		return nil, CodeError{code: 400, msg: fmt.Sprintf("'%v' was read as a plus code, but it could not be decoded: %v", input, err.Error())}
+	}
+	lat, lon := area.Center()
+	return &t.Coordinates{Latitude: lat, Longitude: lon}, nil
+}
@@ -0,0 +1,25 @@
+package wipercheck
+
+import "testing"
+
+func TestDecimalMatch(t *testing.T) {
+	tests := []struct {
+		input string
+		match bool
+	}{
+		{"42.33,-83.04", true},
+		{"42.33 -83.04", true},
+		{"42.33, -83.04", true},
+		{"42,-83", true},
+		{"42 -83.04", true},
+		// bare integers are more likely part of an address than coordinates
+		{"42 83", false},
+		{"+42 -83", false},
+		{"detroit", false},
+	}
+	for _, test := range tests {
+		if match := decimalMatch(test.input) != nil; match != test.match {
+			t.Errorf("decimalMatch(%q) matched = %v, want %v", test.input, match, test.match)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package wipercheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/common"
+	"github.com/evanhutnik/wipercheck-service/internal/geocode"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"golang.org/x/sync/errgroup"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultDepartureHour is the local hour each day after the first departs at if not configured
+	defaultDepartureHour = 8
+	// maxDailyLimit is the longest daily driving limit in hours that can be requested
+	maxDailyLimit = 16
+	// maxDays is the most days a trip can be split into
+	maxDays = 7
+	// maxSnapKm is the furthest an overnight stop is moved to reach a locality
+	maxSnapKm = 40
+	// minRest is the shortest break between a day's arrival and the next day's departure
+	minRest = 4 * time.Hour
+	// minStopPopulation is the population of a locality sizable enough to have somewhere to stay the night,
+	// when the geocoder knows the population of places
+	minStopPopulation = 5000
+)
+
+type ItineraryDay struct {
+	// Departure and Arrival are the day's unix timestamps
+	Departure int64 `json:"departure"`
+	Arrival   int64 `json:"arrival"`
+	// Stop is where the day ends, which is omitted for the last day ending at the destination
+	Stop    *t.Place         `json:"stop,omitempty"`
+	Journey *JourneyResponse `json:"journey"`
+}
+
+// itineraryDay is a day of driving before its weather has been forecast
+type itineraryDay struct {
+	trip      *t.Trip
+	route     *t.Route
+	departure int64
+	stop      *t.Place
+}
+
+// itinerary splits a trip longer than the daily limit into days and forecasts each day at its real times
+func (s *Service) itinerary(ctx context.Context, trip *t.Trip, route *t.Route, departure int64, req *JourneyRequest) (*JourneyResponse, error) {
+	days, err := s.itineraryDays(ctx, trip, route, departure, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &JourneyResponse{
+		Days: make([]ItineraryDay, len(days)),
+	}
+	g := new(errgroup.Group)
+	for i, day := range days {
+		i, day := i, day
+		g.Go(func() error {
+			journey, err := s.journey(ctx, day.trip, day.route, day.departure, dayRequest(req, i, len(days)))
+			if err != nil {
+				return err
+			}
+			resp.Days[i] = ItineraryDay{
+				Departure: day.departure,
+				Arrival:   day.departure + int64(day.route.Duration),
+				Stop:      day.stop,
+				Journey:   journey,
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	var summaries []*t.TripSummary
+	for _, day := range resp.Days {
+		summaries = append(summaries, day.Journey.TripSummary)
+	}
+	resp.TripSummary = combineSummaries(summaries...)
+	return resp, nil
+}
+
+// itineraryDays splits a trip longer than the daily limit into days ending at overnight stops,
+// departing at the morning hour after the first day
+func (s *Service) itineraryDays(ctx context.Context, trip *t.Trip, route *t.Route, departure int64, req *JourneyRequest) ([]itineraryDay, error) {
+	limit := req.dailyLimit * 3600
+	var days []itineraryDay
+	from, dayRoute, dayDeparture := trip.From, route, departure
+	for {
+		if dayRoute.Duration <= limit {
+			days = append(days, itineraryDay{
+				trip:      &t.Trip{From: from, To: trip.To},
+				route:     dayRoute,
+				departure: dayDeparture,
+			})
+			break
+		}
+		if len(days) == maxDays-1 {
+			return nil, CodeError{code: 400, msg: fmt.Sprintf("The trip would take more than %v days with a 'dailyLimit' of %v hours", maxDays, req.dailyLimit)}
+		}
+
+		coords, err := stopCoordinates(dayRoute, limit)
+		if err != nil {
+			return nil, err
+		}
+		stop := s.overnightStop(ctx, coords, req.lang)
+		dayTrip := &t.Trip{From: from, To: &stop.Coordinates}
+		stopRoute, err := s.tripRoute(ctx, dayTrip, req.routeOptions())
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, itineraryDay{
+			trip:      dayTrip,
+			route:     stopRoute,
+			departure: dayDeparture,
+			stop:      stop,
+		})
+
+		from = dayTrip.To
+		dayDeparture = s.nextMorning(dayDeparture+int64(stopRoute.Duration), *from, req.departureHour)
+		dayRoute, err = s.tripRoute(ctx, &t.Trip{From: from, To: trip.To}, req.routeOptions())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return days, nil
+}
+
+// dayRequest returns the request for a day of an itinerary, as the origin window only applies to the first day and
+// the destination window to the last day
+func dayRequest(req *JourneyRequest, day int, days int) *JourneyRequest {
+	dayReq := *req
+	if day > 0 {
+		dayReq.originWindow = 0
+	}
+	if day < days-1 {
+		dayReq.destinationWindow = 0
+	}
+	return &dayReq
+}
+
+// stopCoordinates returns the point along the route's geometry reached after driving for the limit in seconds,
+// assuming a constant speed within each step
+func stopCoordinates(route *t.Route, limit float64) (t.Coordinates, error) {
+	if len(route.Geometry) == 0 || route.Distance <= 0 || route.Duration <= 0 {
+		return t.Coordinates{}, errors.New("no route geometry to place an overnight stop on")
+	}
+	// estimating the distance driven from the average speed, unless the steps are more precise
+	driven := route.Distance * limit / route.Duration
+	var elapsed, distance float64
+	for _, step := range route.Steps {
+		if step.StepDuration > 0 && elapsed+step.StepDuration >= limit {
+			driven = distance + step.StepDistance*(limit-elapsed)/step.StepDuration
+			break
+		}
+		elapsed += step.StepDuration
+		distance += step.StepDistance
+	}
+
+	// the geometry's length differs slightly from the route's distance, so walking the same share of it
+	var length float64
+	for i := 1; i < len(route.Geometry); i++ {
+		length += common.Distance(route.Geometry[i-1], route.Geometry[i])
+	}
+	remaining := length * math.Min(driven/route.Distance, 1)
+	for i := 1; i < len(route.Geometry); i++ {
+		from, to := route.Geometry[i-1], route.Geometry[i]
+		segment := common.Distance(from, to)
+		if segment > 0 && remaining <= segment {
+			share := remaining / segment
+			return t.Coordinates{
+				Latitude:  from.Latitude + (to.Latitude-from.Latitude)*share,
+				Longitude: from.Longitude + (to.Longitude-from.Longitude)*share,
+			}, nil
+		}
+		remaining -= segment
+	}
+	return route.Geometry[len(route.Geometry)-1], nil
+}
+
+// overnightStop snaps the coordinates to the nearest sizable locality if the geocoder knows the population of places,
+// or otherwise to the nearby locality found by reverse geocoding them, keeping the coordinates if there is no locality
+// close enough
+func (s *Service) overnightStop(ctx context.Context, coords t.Coordinates, lang string) *t.Place {
+	if finder, ok := s.gc.(geocode.LocalityFinder); ok {
+		place, err := finder.NearestLocality(ctx, coords, minStopPopulation, lang)
+		if err != nil {
+			s.Logger.Warnf("Error finding locality for overnight stop (%v,%v): %v", coords.Latitude, coords.Longitude, err.Error())
+		} else if place != nil && common.Distance(place.Coordinates, coords) <= maxSnapKm {
+			return place
+		}
+	}
+
+	stop := &t.Place{Coordinates: coords}
+	location, err := s.gc.ReverseGeoCode(ctx, coords, lang)
+	if err != nil {
+		s.Logger.Warnf("Error reverse geocoding overnight stop (%v,%v): %v", coords.Latitude, coords.Longitude, err.Error())
+		return stop
+	} else if location == nil || location.Locality == "" {
+		return stop
+	}
+	stop.Label = summaryStepLocation(location, coords)
+
+	places, err := s.gc.Search(ctx, location.Locality, geocode.SearchOptions{Limit: 1, Region: location.Region, Lang: lang})
+	if err != nil {
+		s.Logger.Warnf("Error geocoding overnight stop %v: %v", stop.Label, err.Error())
+		return stop
+	}
+	if len(places) > 0 && common.Distance(places[0].Coordinates, coords) <= maxSnapKm {
+		stop.Coordinates = places[0].Coordinates
+		stop.Confidence = places[0].Confidence
+	}
+	return stop
+}
+
+// nextMorning returns the unix time of the first departure hour after resting from the arrival, in the local time of the coordinates
+func (s *Service) nextMorning(arrival int64, coords t.Coordinates, hour int) int64 {
+	loc := s.timeZone(coords)
+	earliest := time.Unix(arrival, 0).Add(minRest).In(loc)
+	morning := time.Date(earliest.Year(), earliest.Month(), earliest.Day(), hour, 0, 0, 0, loc)
+	if morning.Before(earliest) {
+		morning = time.Date(earliest.Year(), earliest.Month(), earliest.Day()+1, hour, 0, 0, 0, loc)
+	}
+	return morning.Unix()
+}
+
+// timeZone returns the time zone at the coordinates if the geocoder knows it, estimating it from the longitude otherwise
+func (s *Service) timeZone(coords t.Coordinates) *time.Location {
+	if name := s.timeZoneName(coords); name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return stepLocation(t.Step{Coordinates: coords})
+}
+
+// timeZoneName returns the IANA name of the time zone at the coordinates, or "" if the geocoder doesn't know it
+func (s *Service) timeZoneName(coords t.Coordinates) string {
+	if tz, ok := s.gc.(geocode.TimeZoner); ok {
+		return tz.TimeZone(coords)
+	}
+	return ""
+}
+
+// itineraryParams sets the daily driving limit and morning departure hour of the request, defaulting to the service's configuration
+func (s *Service) itineraryParams(r *http.Request, req *JourneyRequest) error {
+	req.dailyLimit = s.dailyLimit
+	if r.URL.Query().Get("dailyLimit") != "" {
+		limit, err := strconv.ParseFloat(r.URL.Query().Get("dailyLimit"), 64)
+		if err != nil || limit < 1 || limit > maxDailyLimit {
+			return CodeError{code: 400, msg: fmt.Sprintf("'dailyLimit' parameter must be between 1 and %v hours", maxDailyLimit)}
+		}
+		req.dailyLimit = limit
+	}
+	req.departureHour = s.departureHour
+	if r.URL.Query().Get("departureHour") != "" {
+		hour, err := strconv.Atoi(r.URL.Query().Get("departureHour"))
+		if err != nil || hour < 0 || hour > 23 {
+			return CodeError{code: 400, msg: "'departureHour' parameter must be between 0 and 23"}
+		}
+		req.departureHour = hour
+	}
+	return nil
+}
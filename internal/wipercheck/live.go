@@ -0,0 +1,264 @@
+package wipercheck
+
+import (
+	"context"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/common"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"github.com/gorilla/websocket"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// offRouteKm is how far a fix can be from the route before the vehicle is rerouted, allowing for GPS error
+	offRouteKm = 0.5
+	// liveRefresh is how much driving or time passes before the weather ahead is forecast again
+	liveRefresh = 5 * time.Minute
+	// liveTimeout is how long the connection is kept open without a fix
+	liveTimeout = 5 * time.Minute
+)
+
+var upgrader = websocket.Upgrader{}
+
+// LiveFix is a GPS position sent by the client
+type LiveFix struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Time is when the fix was taken as a unix timestamp, defaulting to when it's received
+	Time int64 `json:"time,omitempty"`
+}
+
+// LiveMessage is sent to the client when the route changes, the weather ahead changes or the fix can't be handled
+type LiveMessage struct {
+	// Type is 'route', 'weatherAhead' or 'error'
+	Type  string `json:"type"`
+	Error string `json:"error,omitempty"`
+	// Rerouted is set on route messages for routes found after leaving the previous route
+	Rerouted bool `json:"rerouted,omitempty"`
+	// RemainingMinutes and RemainingKm are the rest of the trip from the latest fix
+	RemainingMinutes float64        `json:"remainingMinutes,omitempty"`
+	RemainingKm      float64        `json:"remainingKm,omitempty"`
+	Briefing         string         `json:"briefing,omitempty"`
+	TripSummary      *t.TripSummary `json:"tripSummary,omitempty"`
+	Steps            []t.Step       `json:"steps,omitempty"`
+}
+
+// liveDrive is the state of a vehicle's drive to its destination
+type liveDrive struct {
+	req   *JourneyRequest
+	to    *t.Coordinates
+	route *t.Route
+	// lengths are the distances in km along the route's geometry to each of its points
+	lengths []float64
+	// forecastAt is the time and progress along the route in seconds at the last forecast
+	forecastAt       time.Time
+	forecastProgress float64
+	signature        string
+}
+
+// LiveHandler is the handler for the /journey/live WebSocket endpoint, following a moving vehicle's GPS fixes
+// and pushing the weather ahead whenever it changes
+func (s *Service) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	drive, err := s.liveRequest(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already responded with the error
+		return
+	}
+	defer conn.Close()
+
+	ctx := r.Context()
+	for {
+		conn.SetReadDeadline(time.Now().Add(liveTimeout))
+		var fix LiveFix
+		if err := conn.ReadJSON(&fix); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.Logger.Infof("Closing live drive: %v", err.Error())
+			}
+			return
+		}
+		for _, msg := range s.liveFix(ctx, drive, fix) {
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// liveRequest validates the live drive's parameters and geocodes its destination
+func (s *Service) liveRequest(r *http.Request) (*liveDrive, error) {
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		return nil, CodeError{code: 400, msg: "Missing 'to' query parameter in request"}
+	}
+	req := &JourneyRequest{
+		to:      to,
+		country: r.URL.Query().Get("country"),
+		region:  r.URL.Query().Get("region"),
+	}
+	var err error
+	if req.lang, err = langParam(r); err != nil {
+		return nil, err
+	}
+	if err := s.routeParams(r, req); err != nil {
+		return nil, err
+	}
+	coords, err := s.geoCode(r.Context(), to, req.searchOptions(), 0)
+	if err != nil {
+		return nil, err
+	}
+	return &liveDrive{req: req, to: coords}, nil
+}
+
+// liveFix snaps the fix to the route, rerouting if the vehicle left it, and returns the messages for the client
+func (s *Service) liveFix(ctx context.Context, drive *liveDrive, fix LiveFix) []LiveMessage {
+	if math.Abs(fix.Latitude) > 90 || math.Abs(fix.Longitude) > 180 {
+		return []LiveMessage{{Type: "error", Error: "Invalid fix coordinates"}}
+	}
+	now := time.Now()
+	if fix.Time > 0 {
+		now = time.Unix(fix.Time, 0)
+	}
+	position := t.Coordinates{Latitude: fix.Latitude, Longitude: fix.Longitude}
+
+	var messages []LiveMessage
+	offKm, alongKm := math.Inf(1), 0.0
+	if drive.route != nil {
+		offKm, alongKm = snapToRoute(drive.route.Geometry, drive.lengths, position)
+	}
+	if offKm > offRouteKm {
+		rerouted := drive.route != nil
+		route, err := s.tripRoute(ctx, &t.Trip{From: &position, To: drive.to}, drive.req.routeOptions())
+		if err != nil {
+			return []LiveMessage{{Type: "error", Error: err.Error()}}
+		} else if len(route.Geometry) == 0 || len(route.Steps) == 0 {
+			return []LiveMessage{{Type: "error", Error: "The router returned a route without a geometry"}}
+		}
+		drive.route, drive.lengths = route, routeLengths(route.Geometry)
+		drive.forecastAt, drive.forecastProgress = time.Time{}, 0
+		alongKm = 0
+		messages = append(messages, LiveMessage{
+			Type:             "route",
+			Rerouted:         rerouted,
+			RemainingMinutes: math.Round(route.Duration / 60),
+			RemainingKm:      math.Round(route.Distance/100) / 10,
+		})
+	}
+
+	progress, remaining := remainingRoute(drive.route, drive.lengths, alongKm, position)
+	// forecasting again only once the vehicle has driven, or time has passed, enough to change the weather ahead
+	if !drive.forecastAt.IsZero() && progress-drive.forecastProgress < liveRefresh.Seconds() && now.Sub(drive.forecastAt) < liveRefresh {
+		return messages
+	}
+	drive.forecastAt, drive.forecastProgress = now, progress
+
+	steps := s.weather(ctx, remaining, now.Unix(), drive.req.lang)
+	markHazards(steps, drive.req.vehicle, drive.req.lang)
+	signature := aheadSignature(steps, drive.req.lang)
+	if signature == drive.signature {
+		return messages
+	}
+	drive.signature = signature
+	return append(messages, LiveMessage{
+		Type:             "weatherAhead",
+		RemainingMinutes: math.Round(remaining.Duration / 60),
+		RemainingKm:      math.Round(remaining.Distance/100) / 10,
+		Briefing:         briefing(steps, drive.req.lang),
+		TripSummary:      s.tripSummary(remaining, steps),
+		Steps:            steps,
+	})
+}
+
+// routeLengths returns the distance in km along the geometry to each of its points
+func routeLengths(geometry []t.Coordinates) []float64 {
+	lengths := make([]float64, len(geometry))
+	for i := 1; i < len(geometry); i++ {
+		lengths[i] = lengths[i-1] + common.Distance(geometry[i-1], geometry[i])
+	}
+	return lengths
+}
+
+// snapToRoute returns the distance in km from the position to the nearest point on the route's geometry,
+// and the distance in km along the route to that point
+func snapToRoute(geometry []t.Coordinates, lengths []float64, position t.Coordinates) (float64, float64) {
+	nearest, along := math.Inf(1), 0.0
+	cosLat := math.Cos(position.Latitude * math.Pi / 180)
+	for i := 0; i+1 < len(geometry); i++ {
+		// projecting the position onto the segment on a local flat projection, which is accurate at these distances
+		ax := (geometry[i].Longitude - position.Longitude) * kmPerDegree * cosLat
+		ay := (geometry[i].Latitude - position.Latitude) * kmPerDegree
+		bx := (geometry[i+1].Longitude - position.Longitude) * kmPerDegree * cosLat
+		by := (geometry[i+1].Latitude - position.Latitude) * kmPerDegree
+		dx, dy := bx-ax, by-ay
+		fraction := 0.0
+		if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+			fraction = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lengthSq))
+		}
+		if distance := math.Hypot(ax+fraction*dx, ay+fraction*dy); distance < nearest {
+			nearest = distance
+			along = lengths[i] + fraction*(lengths[i+1]-lengths[i])
+		}
+	}
+	if len(geometry) == 1 {
+		nearest = common.Distance(geometry[0], position)
+	}
+	return nearest, along
+}
+
+// remainingRoute returns the seconds driven along the route at the distance along it, and the rest of the route
+// starting at the position
+func remainingRoute(route *t.Route, lengths []float64, alongKm float64, position t.Coordinates) (float64, *t.Route) {
+	// the router's distances can differ slightly from the geometry's, so the distance is scaled to the route's
+	along := alongKm * 1000
+	if total := lengths[len(lengths)-1]; total > 0 {
+		along = alongKm / total * route.Distance
+	}
+
+	remaining := &t.Route{}
+	var distance, duration, progress float64
+	for _, step := range route.Steps {
+		end := distance + step.StepDistance
+		switch {
+		case end <= along:
+			// already driven, which is all the progress if the position is at the end of the step
+			progress = duration + step.StepDuration
+		case distance < along:
+			// the step being driven, which is replaced by the part of it left to drive from the position
+			fraction := (along - distance) / step.StepDistance
+			progress = duration + fraction*step.StepDuration
+			current := step
+			current.Coordinates = position
+			current.StepDuration = step.StepDuration * (1 - fraction)
+			current.StepDistance = end - along
+			remaining.Steps = append(remaining.Steps, current)
+		default:
+			remaining.Steps = append(remaining.Steps, step)
+		}
+		distance, duration = end, duration+step.StepDuration
+	}
+	remaining.Duration = math.Max(0, route.Duration-progress)
+	remaining.Distance = math.Max(0, route.Distance-along)
+	return progress, remaining
+}
+
+// aheadSignature describes the weather ahead coarsely enough that it only changes when the conditions meaningfully do:
+// the kinds of weather in order with when they start to the nearest 15 minutes, and whether they are hazardous
+func aheadSignature(steps []t.Step, lang string) string {
+	var parts []string
+	for _, seg := range briefingSegments(steps, lang) {
+		start := time.Unix(steps[seg.start].Arrival, 0).Round(15 * time.Minute).Unix()
+		hazardous := false
+		for _, step := range steps[seg.start : seg.end+1] {
+			hazardous = hazardous || len(step.Hazards) > 0
+		}
+		parts = append(parts, fmt.Sprintf("%v/%v/%v/%v@%v", seg.kind, seg.wet, seg.likely, hazardous, start))
+	}
+	return strings.Join(parts, "|")
+}
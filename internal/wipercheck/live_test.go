@@ -0,0 +1,70 @@
+package wipercheck
+
+import (
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"math"
+	"testing"
+)
+
+func TestSnapToRoute(tt *testing.T) {
+	// a 20 km route due east along the equator, with a step per 0.1 degree segment and an arrival step
+	geometry := []t.Coordinates{{Longitude: 0}, {Longitude: 0.1}, {Longitude: 0.2}}
+	route := &t.Route{
+		Geometry: geometry,
+		Distance: 20000,
+		Duration: 1200,
+		Steps: []t.Step{
+			{Name: "A", StepDistance: 10000, StepDuration: 600, Coordinates: geometry[0]},
+			{Name: "B", StepDistance: 10000, StepDuration: 600, Coordinates: geometry[1]},
+			{Coordinates: geometry[2]},
+		},
+	}
+	lengths := routeLengths(geometry)
+
+	tests := []struct {
+		name     string
+		position t.Coordinates
+		offKm    float64
+		progress float64
+		// steps are the names of the remaining steps, the first being located at the position if partly driven
+		steps    []string
+		duration float64
+		distance float64
+	}{
+		{"on the route", t.Coordinates{Longitude: 0.1}, 0, 600, []string{"B", ""}, 600, 10000},
+		{"partway through a step", t.Coordinates{Longitude: 0.05}, 0, 300, []string{"A", "B", ""}, 900, 15000},
+		{"off the route", t.Coordinates{Latitude: 0.01, Longitude: 0.05}, 1.11, 300, []string{"A", "B", ""}, 900, 15000},
+		{"past the end", t.Coordinates{Longitude: 0.25}, 5.56, 1200, nil, 0, 0},
+	}
+	for _, test := range tests {
+		tt.Run(test.name, func(tt *testing.T) {
+			off, along := snapToRoute(geometry, lengths, test.position)
+			if math.Abs(off-test.offKm) > 0.01 {
+				tt.Errorf("expected the fix %v km off the route, got %v", test.offKm, off)
+			}
+			if (off > offRouteKm) != (test.offKm > offRouteKm) {
+				tt.Errorf("expected rerouting to be %v", test.offKm > offRouteKm)
+			}
+
+			progress, remaining := remainingRoute(route, lengths, along, test.position)
+			if math.Abs(progress-test.progress) > 1e-6 {
+				tt.Errorf("expected %v s driven, got %v", test.progress, progress)
+			}
+			if math.Abs(remaining.Duration-test.duration) > 1e-6 || math.Abs(remaining.Distance-test.distance) > 1e-6 {
+				tt.Errorf("expected %v s and %v m remaining, got %v s and %v m",
+					test.duration, test.distance, remaining.Duration, remaining.Distance)
+			}
+			if len(remaining.Steps) != len(test.steps) {
+				tt.Fatalf("expected remaining steps %v, got %+v", test.steps, remaining.Steps)
+			}
+			for i, step := range remaining.Steps {
+				if step.Name != test.steps[i] {
+					tt.Errorf("step %d: expected %q, got %q", i, test.steps[i], step.Name)
+				}
+			}
+			if len(remaining.Steps) == len(route.Steps) && remaining.Steps[0].Coordinates != test.position {
+				tt.Errorf("expected the partly driven step to start at the fix, got %v", remaining.Steps[0].Coordinates)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package wipercheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/i18n"
+	"github.com/evanhutnik/wipercheck-service/internal/routing"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"golang.org/x/sync/errgroup"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxMatrixPoints is the most origins and the most destinations a matrix can have
+	maxMatrixPoints = 10
+	// matrixConcurrency is the most weather samples fetched at once for a matrix
+	matrixConcurrency = 8
+)
+
+// matrixSamples are the fractions of the way along the straight line from origin to destination that the weather is
+// sampled at, as the table service doesn't return the routes' geometry
+var matrixSamples = []float64{1.0 / 6, 0.5, 5.0 / 6}
+
+type MatrixResponse struct {
+	Error        string          `json:"error,omitempty"`
+	Origins      []t.Coordinates `json:"origins,omitempty"`
+	Destinations []t.Coordinates `json:"destinations,omitempty"`
+	// Pairs has a row for each origin with a column for each destination
+	Pairs [][]MatrixPair `json:"pairs,omitempty"`
+}
+
+type MatrixPair struct {
+	Duration float64 `json:"duration"`
+	Distance float64 `json:"distance"`
+	// StraightLineExposure is a 0-100 score of the chance and severity of precipitation sampled on the straight line
+	// between the origin and destination. It's only an estimate of the weather on the route, which can stray far from
+	// the straight line around water or mountains
+	StraightLineExposure float64 `json:"straightLineExposure"`
+	Error                string  `json:"error,omitempty"`
+}
+
+// matrixSample is a point and hour the weather is sampled at, rounded so that nearby samples are shared
+type matrixSample struct {
+	lat  float64
+	long float64
+	hour int64
+}
+
+// MatrixHandler is the handler for the /matrix endpoint, comparing the trips between several origins and destinations
+func (s *Service) MatrixHandler(w http.ResponseWriter, r *http.Request) {
+	resp, err := s.Matrix(r.Context(), r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	bodyBytes, _ := json.Marshal(resp)
+	w.WriteHeader(200)
+	io.WriteString(w, string(bodyBytes[:]))
+}
+
+// Matrix finds the duration, distance and precipitation exposure from each origin to each destination using
+// the router's table service, with the exposure estimated from the weather on the straight line between them
+func (s *Service) Matrix(ctx context.Context, r *http.Request) (*MatrixResponse, error) {
+	origins, destinations := r.URL.Query()["origin"], r.URL.Query()["destination"]
+	if len(origins) == 0 || len(origins) > maxMatrixPoints {
+		return nil, CodeError{code: 400, msg: fmt.Sprintf("Between 1 and %v 'origin' query parameters are required", maxMatrixPoints)}
+	} else if len(destinations) == 0 || len(destinations) > maxMatrixPoints {
+		return nil, CodeError{code: 400, msg: fmt.Sprintf("Between 1 and %v 'destination' query parameters are required", maxMatrixPoints)}
+	}
+	req := &JourneyRequest{
+		lang:    i18n.DefaultLang,
+		country: r.URL.Query().Get("country"),
+		region:  r.URL.Query().Get("region"),
+	}
+	if r.URL.Query().Get("delay") != "" {
+		delay, err := strconv.ParseInt(r.URL.Query().Get("delay"), 10, 64)
+		if err != nil || delay > 720 {
+			return nil, CodeError{code: 400, msg: "'delay' parameter must be less than 720 minutes (12 hours)"}
+		}
+		req.delay = delay
+	}
+	if err := s.routeParams(r, req); err != nil {
+		return nil, err
+	}
+	tabler, ok := s.router.(routing.Tabler)
+	if !ok {
+		return nil, CodeError{code: 501, msg: "The configured router doesn't support /matrix"}
+	}
+
+	resp := &MatrixResponse{
+		Origins:      make([]t.Coordinates, len(origins)),
+		Destinations: make([]t.Coordinates, len(destinations)),
+	}
+	opts := req.searchOptions()
+	g := new(errgroup.Group)
+	for i, address := range append(origins, destinations...) {
+		i, address := i, address
+		g.Go(func() error {
+			coords, err := s.geoCode(ctx, address, opts, 0)
+			if err != nil {
+				return err
+			}
+			if i < len(origins) {
+				resp.Origins[i] = *coords
+			} else {
+				resp.Destinations[i-len(origins)] = *coords
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	table, err := tabler.Table(ctx, resp.Origins, resp.Destinations, req.routeOptions())
+	if err != nil {
+		s.Logger.Errorw(err.Error(), "action", "Matrix")
+		return nil, CodeError{code: 500, msg: "Internal error retrieving trip routes."}
+	}
+
+	// delay is in minutes while durations are in seconds
+	departure := time.Now().Unix() + req.delay*60
+	resp.Pairs = make([][]MatrixPair, len(origins))
+	pairSamples := make([][][]matrixSample, len(origins))
+	sampled := make(map[matrixSample]bool)
+	var samples []matrixSample
+	for i, origin := range resp.Origins {
+		resp.Pairs[i] = make([]MatrixPair, len(destinations))
+		pairSamples[i] = make([][]matrixSample, len(destinations))
+		for j, destination := range resp.Destinations {
+			duration, distance := table.Durations[i][j], table.Distances[i][j]
+			if duration == nil || distance == nil {
+				resp.Pairs[i][j].Error = "No route found"
+				continue
+			}
+			resp.Pairs[i][j].Duration = math.Round(*duration)
+			resp.Pairs[i][j].Distance = math.Round(*distance)
+			for _, fraction := range matrixSamples {
+				sample := matrixSample{
+					lat:  math.Round((origin.Latitude+(destination.Latitude-origin.Latitude)*fraction)*10) / 10,
+					long: math.Round((origin.Longitude+(destination.Longitude-origin.Longitude)*fraction)*10) / 10,
+					hour: unixHour(departure + int64(*duration*fraction)),
+				}
+				pairSamples[i][j] = append(pairSamples[i][j], sample)
+				if !sampled[sample] {
+					sampled[sample] = true
+					samples = append(samples, sample)
+				}
+			}
+		}
+	}
+	weather := s.sampleWeather(ctx, samples)
+
+	for i := range resp.Pairs {
+		for j := range resp.Pairs[i] {
+			var score float64
+			var count int
+			for _, sample := range pairSamples[i][j] {
+				if w := weather[sample]; w != nil {
+					score += w.Pop * severity(precipKind(w.Conditions))
+					count++
+				}
+			}
+			if count > 0 {
+				resp.Pairs[i][j].StraightLineExposure = math.Round(math.Min(100, score/float64(count)*100)*10) / 10
+			}
+		}
+	}
+	return resp, nil
+}
+
+// sampleWeather fetches the weather for each sample, omitting samples whose weather couldn't be fetched
+func (s *Service) sampleWeather(ctx context.Context, samples []matrixSample) map[matrixSample]*t.Weather {
+	weather := make(map[matrixSample]*t.Weather)
+	var mu sync.Mutex
+	wg := new(sync.WaitGroup)
+	// limiting how many samples are fetched at once, as a full matrix can need hundreds
+	sem := make(chan struct{}, matrixConcurrency)
+	for _, sample := range samples {
+		sample := sample
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			hourly, err := s.hourlyWeather(ctx, t.Coordinates{Latitude: sample.lat, Longitude: sample.long}, sample.hour, i18n.DefaultLang)
+			if err != nil {
+				s.Logger.Warnf("Error getting hourly weather data: %v", err.Error())
+				return
+			}
+			mu.Lock()
+			weather[sample] = hourly
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return weather
+}
@@ -0,0 +1,163 @@
+package wipercheck
+
+import (
+	"fmt"
+	"github.com/evanhutnik/wipercheck-service/internal/geocode"
+	"github.com/evanhutnik/wipercheck-service/internal/geonames"
+	"github.com/evanhutnik/wipercheck-service/internal/graphhopper"
+	"github.com/evanhutnik/wipercheck-service/internal/metno"
+	"github.com/evanhutnik/wipercheck-service/internal/nominatim"
+	"github.com/evanhutnik/wipercheck-service/internal/nws"
+	"github.com/evanhutnik/wipercheck-service/internal/openmeteo"
+	ow "github.com/evanhutnik/wipercheck-service/internal/openweather"
+	"github.com/evanhutnik/wipercheck-service/internal/osrm"
+	ps "github.com/evanhutnik/wipercheck-service/internal/positionstack"
+	"github.com/evanhutnik/wipercheck-service/internal/routing"
+	"github.com/evanhutnik/wipercheck-service/internal/valhalla"
+	"github.com/evanhutnik/wipercheck-service/internal/weather"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// newWeatherProvider creates the weather provider from the comma-separated provider names, defaulting to OpenWeather.
+// When several providers are configured, their forecasts are either combined using the weights, e.g. 'openweather:2,nws:1',
+// or with the 'failover' strategy tried in order, skipping failing providers for the cool-down period
+func newWeatherProvider(names string, weights string, strategy string, cooldown time.Duration) weather.Provider {
+	providers := strings.Split(names, ",")
+
+	weightsByName := make(map[string]float64)
+	for _, weight := range strings.Split(weights, ",") {
+		if weight == "" {
+			continue
+		}
+		parts := strings.SplitN(weight, ":", 2)
+		value, err := strconv.ParseFloat(strings.TrimSpace(parts[len(parts)-1]), 64)
+		if len(parts) != 2 || err != nil || value <= 0 {
+			panic(fmt.Sprintf("Invalid weight '%v' in weather_weights", weight))
+		}
+		weightsByName[strings.TrimSpace(parts[0])] = value
+	}
+
+	var sources []weather.Source
+	for _, name := range providers {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = "openweather"
+		}
+		source := weather.Source{
+			Name:     name,
+			Provider: newNamedWeatherProvider(name),
+			Weight:   1,
+		}
+		if weight, ok := weightsByName[name]; ok {
+			source.Weight = weight
+		}
+		sources = append(sources, source)
+	}
+
+	switch {
+	case len(sources) == 1, strategy == "failover":
+		return weather.NewChain(cooldown, sources...)
+	case strategy == "", strategy == "ensemble":
+		return weather.NewEnsemble(sources...)
+	default:
+		panic(fmt.Sprintf("Unknown weather_strategy '%v'", strategy))
+	}
+}
+
+// newNamedWeatherProvider creates the weather provider selected by name, configured from the environment
+func newNamedWeatherProvider(name string) weather.Provider {
+	switch name {
+	case "openweather":
+		return ow.New(
+			ow.ApiKeyOption(os.Getenv("openweather_apikey")),
+			ow.BaseUrlOption(os.Getenv("openweather_baseurl")),
+		)
+	case "openmeteo":
+		return openmeteo.New(
+			openmeteo.BaseUrlOption(os.Getenv("openmeteo_baseurl")),
+		)
+	case "nws":
+		return nws.New(
+			nws.BaseUrlOption(os.Getenv("nws_baseurl")),
+			nws.UserAgentOption(os.Getenv("nws_useragent")),
+		)
+	case "metno":
+		return metno.New(
+			metno.BaseUrlOption(os.Getenv("metno_baseurl")),
+			metno.UserAgentOption(os.Getenv("metno_useragent")),
+		)
+	default:
+		panic(fmt.Sprintf("Unknown weather_provider '%v'", name))
+	}
+}
+
+// newGeocoder creates the geocoder selected by name, defaulting to PositionStack.
+// A different geocoder can be used for reverse lookups by naming it as the reverse geocoder
+func newGeocoder(name string, reverse string) geocode.Geocoder {
+	forward := newNamedGeocoder(name)
+	if reverse == "" || reverse == name {
+		return forward
+	}
+	return geocode.Split{
+		Forward: forward,
+		Reverse: newNamedGeocoder(reverse),
+	}
+}
+
+// newNamedGeocoder creates the geocoder selected by name, configured from the environment
+func newNamedGeocoder(name string) geocode.Geocoder {
+	switch name {
+	case "", "positionstack":
+		return ps.New(
+			ps.ApiKeyOption(os.Getenv("positionstack_apikey")),
+			ps.BaseUrlOption(os.Getenv("positionstack_baseurl")),
+		)
+	case "nominatim":
+		opts := []nominatim.ClientOption{
+			nominatim.BaseUrlOption(os.Getenv("nominatim_baseurl")),
+			nominatim.UserAgentOption(os.Getenv("nominatim_useragent")),
+		}
+		if ms, err := strconv.Atoi(os.Getenv("nominatim_interval_ms")); err == nil {
+			opts = append(opts, nominatim.IntervalOption(time.Duration(ms)*time.Millisecond))
+		}
+		return nominatim.New(opts...)
+	case "geonames":
+		return geonames.New(
+			geonames.FileOption(os.Getenv("geonames_file")),
+			geonames.Admin1FileOption(os.Getenv("geonames_admin1_file")),
+		)
+	default:
+		panic(fmt.Sprintf("Unknown geocoder '%v'", name))
+	}
+}
+
+// newRouter creates the router selected by name, configured from the environment and defaulting to OSRM
+func newRouter(name string) routing.Router {
+	switch name {
+	case "", "osrm":
+		// other vehicles are routed by the OSRM server for their profile if one is configured, e.g. osrm_bicycle_baseurl
+		opts := []osrm.ClientOption{
+			osrm.BaseUrlOption(os.Getenv("osrm_baseurl")),
+		}
+		for _, vehicle := range routing.Vehicles {
+			if vehicle != routing.VehicleCar {
+				opts = append(opts, osrm.ProfileUrlOption(vehicle, os.Getenv(fmt.Sprintf("osrm_%v_baseurl", vehicle))))
+			}
+		}
+		return osrm.New(opts...)
+	case "valhalla":
+		return valhalla.New(
+			valhalla.BaseUrlOption(os.Getenv("valhalla_baseurl")),
+		)
+	case "graphhopper":
+		return graphhopper.New(
+			graphhopper.ApiKeyOption(os.Getenv("graphhopper_apikey")),
+			graphhopper.BaseUrlOption(os.Getenv("graphhopper_baseurl")),
+		)
+	default:
+		panic(fmt.Sprintf("Unknown router '%v'", name))
+	}
+}
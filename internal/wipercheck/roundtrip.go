@@ -0,0 +1,174 @@
+package wipercheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"golang.org/x/sync/errgroup"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxStay is the longest time in minutes that can be spent at the destination of a round trip
+const maxStay = 24 * 60
+
+type RoundTripResponse struct {
+	TripSummary *t.TripSummary `json:"tripSummary,omitempty"`
+	// ReturnDeparture is when the return leg leaves the destination, as a unix timestamp
+	ReturnDeparture int64            `json:"returnDeparture,omitempty"`
+	Outbound        *JourneyResponse `json:"outbound,omitempty"`
+	Return          *JourneyResponse `json:"return,omitempty"`
+}
+
+// RoundTripHandler is the handler for the /journey/roundtrip endpoint, forecasting a trip and its return
+func (s *Service) RoundTripHandler(w http.ResponseWriter, r *http.Request) {
+	resp, err := s.RoundTrip(r.Context(), r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	bodyBytes, _ := json.Marshal(resp)
+	w.WriteHeader(200)
+	io.WriteString(w, string(bodyBytes[:]))
+}
+
+// RoundTrip forecasts the outbound trip and the return trip after the stay at the destination,
+// departing early enough to be back by the return-by time if one is given
+func (s *Service) RoundTrip(ctx context.Context, r *http.Request) (*RoundTripResponse, error) {
+	req, err := s.validateRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	stay, returnBy, err := roundTripParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// the endpoints are only geocoded once, with the return trip swapping them
+	trip, err := s.tripCoordinates(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	returnTrip := &t.Trip{
+		From: trip.To,
+		To:   trip.From,
+	}
+
+	var route, returnRoute *t.Route
+	g := new(errgroup.Group)
+	g.Go(func() error {
+		var err error
+		route, err = s.tripRoute(ctx, trip, req.routeOptions())
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		returnRoute, err = s.tripRoute(ctx, returnTrip, req.routeOptions())
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	// delay and stay are in minutes while route durations are in seconds
+	departure := time.Now().Unix() + req.delay*60
+	arrival := departure + int64(route.Duration)
+	returnDeparture := arrival + stay*60
+	if returnBy > 0 {
+		latest := returnBy - int64(returnRoute.Duration)
+		if latest < arrival {
+			return nil, CodeError{code: 400, msg: fmt.Sprintf("The round trip can't be completed by 'returnBy', as the earliest return is %v",
+				time.Unix(arrival+int64(returnRoute.Duration), 0).UTC().Format(time.RFC3339))}
+		}
+		if stay < 0 || latest < returnDeparture {
+			returnDeparture = latest
+		}
+	}
+
+	resp := &RoundTripResponse{
+		ReturnDeparture: returnDeparture,
+	}
+	g = new(errgroup.Group)
+	g.Go(func() error {
+		var err error
+		resp.Outbound, err = s.journey(ctx, trip, route, departure, req)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		resp.Return, err = s.journey(ctx, returnTrip, returnRoute, returnDeparture, req)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	resp.TripSummary = combineSummaries(resp.Outbound.TripSummary, resp.Return.TripSummary)
+	return resp, nil
+}
+
+// roundTripParams returns the minutes to stay at the destination, or -1 if not given, and the unix time to return by, or 0 if not given
+func roundTripParams(r *http.Request) (int64, int64, error) {
+	stay, returnBy := int64(-1), int64(0)
+	if r.URL.Query().Get("stay") != "" {
+		var err error
+		stay, err = strconv.ParseInt(r.URL.Query().Get("stay"), 10, 64)
+		if err != nil || stay < 0 || stay > maxStay {
+			return 0, 0, CodeError{code: 400, msg: fmt.Sprintf("'stay' parameter must be between 0 and %v minutes", maxStay)}
+		}
+	}
+	if r.URL.Query().Get("returnBy") != "" {
+		var err error
+		returnBy, err = strconv.ParseInt(r.URL.Query().Get("returnBy"), 10, 64)
+		if err != nil || returnBy <= time.Now().Unix() {
+			return 0, 0, CodeError{code: 400, msg: "'returnBy' parameter must be a future unix timestamp"}
+		}
+	}
+	if stay < 0 && returnBy == 0 {
+		return 0, 0, CodeError{code: 400, msg: "Missing 'stay' or 'returnBy' query parameter in request"}
+	}
+	return stay, returnBy, nil
+}
+
+// combineSummaries combines the summaries of consecutive legs into a summary of the whole trip
+func combineSummaries(summaries ...*t.TripSummary) *t.TripSummary {
+	combined := &t.TripSummary{}
+	var worstScore, weighted float64
+	for _, summary := range summaries {
+		combined.DurationMinutes += summary.DurationMinutes
+		combined.DistanceKm = math.Round((combined.DistanceKm+summary.DistanceKm)*10) / 10
+		combined.HazardMinutes += summary.HazardMinutes
+		weighted += summary.WiperIndex * summary.DurationMinutes
+
+		if combined.Exposure == nil {
+			combined.Exposure = make([]t.Exposure, len(summary.Exposure))
+		}
+		for i, exposure := range summary.Exposure {
+			combined.Exposure[i].MinPop = exposure.MinPop
+			combined.Exposure[i].Minutes += exposure.Minutes
+			combined.Exposure[i].Km = math.Round((combined.Exposure[i].Km+exposure.Km)*10) / 10
+		}
+
+		if worst := summary.WorstStep; worst != nil {
+			score := worst.Weather.Pop * severity(precipKind(worst.Weather.Conditions))
+			if score > worstScore {
+				worstScore = score
+				combined.WorstStep = worst
+			}
+		}
+		if combined.FirstWetETA == 0 {
+			combined.FirstWetETA = summary.FirstWetETA
+		}
+		if summary.LastWetETA != 0 {
+			combined.LastWetETA = summary.LastWetETA
+		}
+	}
+	// the wiper index of the whole trip is the duration-weighted wiper index of the legs
+	if combined.DurationMinutes > 0 {
+		combined.WiperIndex = math.Round(weighted/combined.DurationMinutes*10) / 10
+	}
+	return combined
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	ow "github.com/evanhutnik/wipercheck-service/internal/openweather"
-	"github.com/evanhutnik/wipercheck-service/internal/osrm"
-	ps "github.com/evanhutnik/wipercheck-service/internal/positionstack"
+	"github.com/evanhutnik/wipercheck-service/internal/geocode"
+	"github.com/evanhutnik/wipercheck-service/internal/i18n"
+	"github.com/evanhutnik/wipercheck-service/internal/places"
+	"github.com/evanhutnik/wipercheck-service/internal/routing"
 	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"github.com/evanhutnik/wipercheck-service/internal/weather"
 	"github.com/go-redis/redis/v8"
 	_ "github.com/joho/godotenv/autoload"
 	"go.uber.org/zap"
@@ -25,14 +27,39 @@ import (
 type JourneyRequest struct {
 	from   string
 	to     string
-	minPop float64
+	filter stepFilter
 	delay  int64
+	format string
+	lang   string
+	// vehicle selects the routing profile and hazard thresholds, while avoid are the road features the route must not use
+	vehicle string
+	avoid   []string
+	// detour enables looking for a detour around hazardous weather
+	detour bool
+	// trips longer than the daily limit in hours are split into days, departing at the hour after the first day
+	dailyLimit    float64
+	departureHour int
+
+	// country and region bias geocoding, while candidates enables returning up to that many choices for ambiguous addresses
+	country    string
+	region     string
+	candidates int
+
+	originWindow      int64
+	destinationWindow int64
 }
 
 type JourneyResponse struct {
-	Error   string          `json:"error,omitempty"`
-	Summary []t.SummaryStep `json:"summary,omitempty"`
-	Steps   []t.Step        `json:"detailedSteps,omitempty"`
+	Error             string               `json:"error,omitempty"`
+	Candidates        map[string][]t.Place `json:"candidates,omitempty"`
+	Briefing          string               `json:"briefing,omitempty"`
+	TripSummary       *t.TripSummary       `json:"tripSummary,omitempty"`
+	OriginWindow      *t.ForecastWindow    `json:"originWindow,omitempty"`
+	DestinationWindow *t.ForecastWindow    `json:"destinationWindow,omitempty"`
+	Detour            *Detour              `json:"detour,omitempty"`
+	Days              []ItineraryDay       `json:"days,omitempty"`
+	Summary           []t.SummaryStep      `json:"summary,omitempty"`
+	Steps             []t.Step             `json:"detailedSteps,omitempty"`
 }
 
 type CodeError struct {
@@ -45,11 +72,24 @@ func (c CodeError) Error() string {
 }
 
 type Service struct {
-	osrm         *osrm.Client
-	ow           *ow.Client
-	psc          *ps.Client
-	rc           *redis.Client
-	disableRedis bool
+	router routing.Router
+	wp     weather.Provider
+	gc     geocode.Geocoder
+	places *places.Registry
+	// autocompletes caches recent autocomplete results
+	autocompletes *autocompleteCache
+	rc            *redis.Client
+	disableRedis  bool
+
+	// weatherMemo shares forecasts between the journeys of a batch, and is nil otherwise
+	weatherMemo *memo
+
+	exposureLevels []float64
+	detourPenalty  float64
+	dailyLimit     float64
+	departureHour  int
+	batchMax       int
+	batchWorkers   int
 
 	Logger *zap.SugaredLogger
 }
@@ -61,19 +101,24 @@ func New() *Service {
 	defer baseLogger.Sync()
 	s.Logger = baseLogger.Sugar()
 
-	s.psc = ps.New(
-		ps.ApiKeyOption(os.Getenv("positionstack_apikey")),
-		ps.BaseUrlOption(os.Getenv("positionstack_baseurl")),
-	)
+	s.gc = newGeocoder(os.Getenv("geocoder"), os.Getenv("reverse_geocoder"))
+	s.autocompletes = newAutocompleteCache()
 
-	s.osrm = osrm.New(
-		osrm.BaseUrlOption(os.Getenv("osrm_baseurl")),
-	)
+	if file := os.Getenv("places_file"); file != "" {
+		registry, err := places.Load(file)
+		if err != nil {
+			panic(fmt.Sprintf("Error loading places_file %v: %v", file, err.Error()))
+		}
+		s.places = registry
+	}
 
-	s.ow = ow.New(
-		ow.ApiKeyOption(os.Getenv("openweather_apikey")),
-		ow.BaseUrlOption(os.Getenv("openweather_baseurl")),
-	)
+	s.router = newRouter(os.Getenv("router"))
+
+	cooldown := 60 * time.Second
+	if seconds, err := strconv.Atoi(os.Getenv("weather_cooldown")); err == nil {
+		cooldown = time.Duration(seconds) * time.Second
+	}
+	s.wp = newWeatherProvider(os.Getenv("weather_provider"), os.Getenv("weather_weights"), os.Getenv("weather_strategy"), cooldown)
 
 	s.rc = redis.NewClient(&redis.Options{
 		Addr: os.Getenv("redis_address"),
@@ -84,13 +129,69 @@ func New() *Service {
 		s.disableRedis = disableRedis
 	}
 
+	s.exposureLevels = defaultExposureLevels
+	if levels := os.Getenv("exposure_levels"); levels != "" {
+		s.exposureLevels = nil
+		for _, level := range strings.Split(levels, ",") {
+			pop, err := strconv.ParseFloat(strings.TrimSpace(level), 64)
+			if err != nil {
+				panic(fmt.Sprintf("Invalid exposure level '%v' in exposure_levels", level))
+			}
+			s.exposureLevels = append(s.exposureLevels, pop)
+		}
+	}
+
+	s.detourPenalty = defaultDetourPenalty
+	if penalty := os.Getenv("detour_penalty"); penalty != "" {
+		s.detourPenalty, err = strconv.ParseFloat(penalty, 64)
+		if err != nil || s.detourPenalty < 0 {
+			panic(fmt.Sprintf("Invalid detour_penalty '%v'", penalty))
+		}
+	}
+
+	if limit := os.Getenv("daily_limit_hours"); limit != "" {
+		s.dailyLimit, err = strconv.ParseFloat(limit, 64)
+		if err != nil || s.dailyLimit < 0 {
+			panic(fmt.Sprintf("Invalid daily_limit_hours '%v'", limit))
+		}
+	}
+	s.departureHour = defaultDepartureHour
+	if hour := os.Getenv("departure_hour"); hour != "" {
+		s.departureHour, err = strconv.Atoi(hour)
+		if err != nil || s.departureHour < 0 || s.departureHour > 23 {
+			panic(fmt.Sprintf("Invalid departure_hour '%v'", hour))
+		}
+	}
+
+	s.batchMax = defaultBatchMax
+	if max := os.Getenv("batch_max_journeys"); max != "" {
+		s.batchMax, err = strconv.Atoi(max)
+		if err != nil || s.batchMax < 1 {
+			panic(fmt.Sprintf("Invalid batch_max_journeys '%v'", max))
+		}
+	}
+	s.batchWorkers = defaultBatchWorkers
+	if workers := os.Getenv("batch_workers"); workers != "" {
+		s.batchWorkers, err = strconv.Atoi(workers)
+		if err != nil || s.batchWorkers < 1 {
+			panic(fmt.Sprintf("Invalid batch_workers '%v'", workers))
+		}
+	}
+
 	return s
 }
 
 func (s *Service) Start() {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/journey", s.JourneyHandler)
+	mux.HandleFunc("/journey/roundtrip", s.RoundTripHandler)
+	mux.HandleFunc("/journey/live", s.LiveHandler)
+	mux.HandleFunc("/journey/stream", s.StreamHandler)
+	mux.HandleFunc("/journeys", s.BatchHandler)
+	mux.HandleFunc("/matrix", s.MatrixHandler)
+	mux.HandleFunc("/places/autocomplete", s.AutocompleteHandler)
 	mux.HandleFunc("/health", s.HealthCheckHandler)
+	mux.HandleFunc("/health/weather", s.WeatherHealthHandler)
 
 	_ = http.ListenAndServe(":8080", mux)
 }
@@ -101,6 +202,17 @@ func (s *Service) HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, "OK")
 }
 
+// WeatherHealthHandler returns the recent error rate and latency of each weather provider
+func (s *Service) WeatherHealthHandler(w http.ResponseWriter, r *http.Request) {
+	var providers []weather.SourceHealth
+	if chain, ok := s.wp.(*weather.Chain); ok {
+		providers = chain.Health()
+	}
+	bodyBytes, _ := json.Marshal(providers)
+	w.WriteHeader(200)
+	io.WriteString(w, string(bodyBytes[:]))
+}
+
 // JourneyHandler is the handler for the /journey endpoint of wipercheck-service
 func (s *Service) JourneyHandler(w http.ResponseWriter, r *http.Request) {
 	resp, err := s.Journey(r.Context(), r)
@@ -123,15 +235,36 @@ func (s *Service) Journey(ctx context.Context, r *http.Request) (*JourneyRespons
 		return nil, err
 	}
 
-	route, err := s.tripRoute(ctx, trip)
+	route, err := s.tripRoute(ctx, trip, req.routeOptions())
 	if err != nil {
 		return nil, err
 	}
 
-	steps := s.weather(ctx, route, req.delay)
+	// delay is in minutes while step durations are in seconds
+	departure := time.Now().Unix() + req.delay*60
+	if req.dailyLimit > 0 && route.Duration > req.dailyLimit*3600 {
+		return s.itinerary(ctx, trip, route, departure, req)
+	}
+	return s.journey(ctx, trip, route, departure, req)
+}
 
-	resp, err := s.response(ctx, steps, req)
+// journey runs the weather pipeline for the trip's route departing at the unix time, generating the response
+func (s *Service) journey(ctx context.Context, trip *t.Trip, route *t.Route, departure int64, req *JourneyRequest) (*JourneyResponse, error) {
+	steps := s.weather(ctx, route, departure, req.lang)
+	markHazards(steps, req.vehicle, req.lang)
+	return s.journeyResponse(ctx, trip, route, steps, departure, req)
+}
 
+// journeyResponse generates the response from the weather steps of the trip's route departing at the unix time
+func (s *Service) journeyResponse(ctx context.Context, trip *t.Trip, route *t.Route, steps []t.Step, departure int64, req *JourneyRequest) (*JourneyResponse, error) {
+	resp, err := s.response(ctx, route, steps, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.detour {
+		resp.Detour = s.detour(ctx, trip, route, resp.TripSummary, steps, departure, req)
+	}
+	s.weatherWindows(ctx, resp, trip, route, departure, req)
 	return resp, nil
 }
 
@@ -148,12 +281,19 @@ func (s *Service) validateRequest(r *http.Request) (*JourneyRequest, error) {
 		from: from,
 		to:   to,
 	}
+	filter, err := parseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		return nil, err
+	}
+	req.filter = filter
+
+	// minPop is kept for compatibility and is equivalent to a 'pop>=' filter
 	minPop, err := strconv.ParseFloat(r.URL.Query().Get("minPop"), 64)
 	if err == nil {
 		if minPop > 100 {
 			return nil, CodeError{code: 400, msg: "'minPop' parameter must be less than 100%"}
 		}
-		req.minPop = minPop
+		req.filter = append(req.filter, filterClause{field: "pop", op: ">=", numbers: []float64{minPop}})
 	}
 
 	if r.URL.Query().Get("delay") != "" {
@@ -164,23 +304,125 @@ func (s *Service) validateRequest(r *http.Request) (*JourneyRequest, error) {
 		req.delay = delay
 	}
 
+	if req.originWindow, err = windowParam(r, "originWindow"); err != nil {
+		return nil, err
+	}
+	if req.destinationWindow, err = windowParam(r, "destinationWindow"); err != nil {
+		return nil, err
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "briefing" {
+		return nil, CodeError{code: 400, msg: "'format' parameter must be 'briefing' if provided"}
+	}
+	req.format = format
+
+	if req.lang, err = langParam(r); err != nil {
+		return nil, err
+	}
+
+	if err := s.routeParams(r, req); err != nil {
+		return nil, err
+	}
+	if err := s.itineraryParams(r, req); err != nil {
+		return nil, err
+	}
+
+	if detour := r.URL.Query().Get("detour"); detour != "" {
+		if req.detour, err = strconv.ParseBool(detour); err != nil {
+			return nil, CodeError{code: 400, msg: "'detour' parameter must be true or false"}
+		}
+	}
+
+	req.country = r.URL.Query().Get("country")
+	if req.country != "" && len(req.country) != 2 {
+		return nil, CodeError{code: 400, msg: "'country' parameter must be a 2 letter ISO 3166 country code"}
+	}
+	req.region = r.URL.Query().Get("region")
+	if r.URL.Query().Get("candidates") != "" {
+		candidates, err := strconv.Atoi(r.URL.Query().Get("candidates"))
+		if err != nil || candidates < 2 || candidates > maxCandidates {
+			return nil, CodeError{code: 400, msg: fmt.Sprintf("'candidates' parameter must be between 2 and %v", maxCandidates)}
+		}
+		req.candidates = candidates
+	}
+
 	return req, nil
 }
 
+// langParam returns the supported language of the request, defaulting to English
+func langParam(r *http.Request) (string, error) {
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		return i18n.DefaultLang, nil
+	} else if !i18n.Supported(lang) {
+		return "", CodeError{code: 400, msg: fmt.Sprintf("Unsupported 'lang' parameter '%v'", lang)}
+	}
+	return lang, nil
+}
+
+// routeParams sets the vehicle and road features to avoid of the request, defaulting to a car
+func (s *Service) routeParams(r *http.Request, req *JourneyRequest) error {
+	req.vehicle = routing.VehicleCar
+	if vehicle := r.URL.Query().Get("vehicle"); vehicle != "" {
+		if _, ok := hazardProfiles[vehicle]; !ok {
+			return CodeError{code: 400, msg: fmt.Sprintf("'vehicle' parameter must be one of %v", strings.Join(routing.Vehicles, ", "))}
+		}
+		if !s.router.Supports(vehicle) {
+			return CodeError{code: 400, msg: fmt.Sprintf("'vehicle' parameter '%v' is not supported by the configured router", vehicle)}
+		}
+		req.vehicle = vehicle
+	}
+
+	if avoid := r.URL.Query().Get("avoid"); avoid != "" {
+		for _, feature := range strings.Split(avoid, ",") {
+			feature = strings.TrimSpace(feature)
+			if !contains(routing.Avoidable, feature) {
+				return CodeError{code: 400, msg: fmt.Sprintf("Invalid 'avoid' value '%v', must be one of %v", feature, strings.Join(routing.Avoidable, ", "))}
+			}
+			if !contains(req.avoid, feature) {
+				req.avoid = append(req.avoid, feature)
+			}
+		}
+		if !s.router.SupportsAvoid(req.vehicle, req.avoid) {
+			return CodeError{code: 400, msg: fmt.Sprintf("Avoiding %v is not supported for vehicle '%v' by the configured router", strings.Join(req.avoid, " and "), req.vehicle)}
+		}
+	}
+	return nil
+}
+
+// routeOptions returns the routing options of the request
+func (req *JourneyRequest) routeOptions() routing.Options {
+	return routing.Options{
+		Vehicle: req.vehicle,
+		Avoid:   req.avoid,
+	}
+}
+
 // tripCoordinates converts the 'to' and 'from' fields from unstructured text to coordinates
 func (s *Service) tripCoordinates(ctx context.Context, req *JourneyRequest) (*t.Trip, error) {
 	var fromCoord, toCoord *t.Coordinates
+	var fromAmbiguous, toAmbiguous *AmbiguousError
+	opts := req.searchOptions()
 	// spinning up separate goroutines to geocode the two addresses simultaneously
 	g := new(errgroup.Group)
 
 	g.Go(func() error {
 		var err error
-		fromCoord, err = s.geoCode(ctx, req.from)
+		fromCoord, err = s.geoCode(ctx, req.from, opts, req.candidates)
+		fromAmbiguous = asAmbiguous(err, "from")
+		if fromAmbiguous != nil {
+			return nil
+		}
 		return err
 	})
 	g.Go(func() error {
 		var err error
-		toCoord, err = s.geoCode(ctx, req.to)
+		toCoord, err = s.geoCode(ctx, req.to, opts, req.candidates)
+		toAmbiguous = asAmbiguous(err, "to")
+		if toAmbiguous != nil {
+			return nil
+		}
 		return err
 	})
 
@@ -188,38 +430,54 @@ func (s *Service) tripCoordinates(ctx context.Context, req *JourneyRequest) (*t.
 	if err := g.Wait(); err != nil {
 		return nil, err
 	}
+	// returning the candidates for both addresses at once if they are both ambiguous
+	if ambiguous := mergeAmbiguous(fromAmbiguous, toAmbiguous); ambiguous != nil {
+		return nil, *ambiguous
+	}
 	return &t.Trip{
 		From: fromCoord,
 		To:   toCoord,
 	}, nil
 }
 
-// geoCode is a wrapper function for handling errors returned from the PositionStack client GeoCode method
-func (s *Service) geoCode(ctx context.Context, address string) (*t.Coordinates, error) {
-	toGeo, err := s.psc.GeoCode(ctx, address)
+// geoCode is a wrapper function for handling errors returned from the geocoder Search method,
+// skipping the geocoder for saved places, coordinates and plus codes.
+// If candidates is set, an AmbiguousError is returned with up to that many choices when the best match is uncertain
+func (s *Service) geoCode(ctx context.Context, address string, opts geocode.SearchOptions, candidates int) (*t.Coordinates, error) {
+	if coords, err := s.parseLocation(ctx, address, opts); coords != nil || err != nil {
+		return coords, err
+	}
+	opts.Limit = 1
+	if candidates > 0 {
+		opts.Limit = candidates
+	}
+	places, err := s.gc.Search(ctx, address, opts)
 	if err != nil {
 		s.Logger.Errorw(err.Error(),
 			"address", address, "action", "GeoCode")
 		return nil, CodeError{code: 500, msg: fmt.Sprintf("Internal error geocoding address '%v'.", address)}
-	} else if toGeo == nil {
+	} else if len(places) == 0 {
 		return nil, CodeError{code: 400, msg: fmt.Sprintf("Unrecognized address '%v'. Check spelling or be more specific.", address)}
 	}
-	return toGeo, err
+	if candidates > 0 && ambiguous(places) {
+		return nil, &AmbiguousError{candidates: places}
+	}
+	return &places[0].Coordinates, nil
 }
 
-// tripRoute is a wrapper function for handling errors returned from the OSRM client Route method
-func (s *Service) tripRoute(ctx context.Context, trip *t.Trip) (*t.Route, error) {
-	route, err := s.osrm.Route(ctx, trip)
+// tripRoute is a wrapper function for handling errors returned from the router Route method
+func (s *Service) tripRoute(ctx context.Context, trip *t.Trip, opts routing.Options) (*t.Route, error) {
+	route, err := s.router.Route(ctx, trip, opts)
 	if err != nil {
 		s.Logger.Errorf("Error routing trip (%v,%v) to (%v,%v): %v",
-			trip.From.Latitude, trip.From.Longitude, trip.To.Latitude, trip.From.Longitude, err.Error())
+			trip.From.Latitude, trip.From.Longitude, trip.To.Latitude, trip.To.Longitude, err.Error())
 		return nil, CodeError{code: 500, msg: "Internal error retrieving trip route."}
 	}
 	return route, nil
 }
 
 // weather returns the relevant forecasted weather data for the user's trip
-func (s *Service) weather(ctx context.Context, route *t.Route, delay int64) []t.Step {
+func (s *Service) weather(ctx context.Context, route *t.Route, departure int64, lang string) []t.Step {
 	steps := s.steps(route)
 
 	// spinning up separate goroutines to analyze weather data of all steps simultaneously
@@ -230,46 +488,7 @@ func (s *Service) weather(ctx context.Context, route *t.Route, delay int64) []t.
 		i, step := i, step
 		go func() {
 			defer wg.Done()
-			unixTime := time.Now().Unix() + int64(step.TotalDuration) + delay
-			stepHour := time.Unix(unixTime, 0).UTC().Truncate(time.Hour).UTC().Unix()
-			// querying for forecasted weather data cached by wipercheck-loader
-			if !s.disableRedis {
-				geoResponse := s.rc.GeoRadius(ctx, strconv.FormatInt(stepHour, 10), step.Coordinates.Longitude, step.Coordinates.Latitude,
-					&redis.GeoRadiusQuery{
-						Radius:    10,
-						Unit:      "km",
-						WithCoord: true,
-						WithDist:  true,
-						Count:     1,
-						Sort:      "ASC",
-					})
-				locations, err := geoResponse.Result()
-				if err != nil {
-					s.Logger.Errorf("Redis error when fetching GeoRadius for (%v, %v): %v",
-						step.Coordinates.Latitude, step.Coordinates.Longitude, err.Error())
-				}
-				if len(locations) > 0 {
-					var redisWeather t.RedisHourlyWeather
-					err := json.Unmarshal([]byte(locations[0].Name), &redisWeather)
-					if err != nil {
-						s.Logger.Errorf("Error unmarshalling redis weather for (%v, %v): %v",
-							step.Coordinates.Latitude, step.Coordinates.Longitude, err.Error())
-					} else {
-						redisWeather.Hourly.Time = stepHour
-						step.Weather = redisWeather.Hourly
-						steps[i] = step
-						return
-					}
-				}
-			}
-			hourly, err := s.ow.GetHourlyWeather(ctx, step.Coordinates, stepHour)
-			if err != nil {
-				s.Logger.Warnf("Error getting hourly weather data: %v", err.Error())
-				return
-			}
-			hourly.Time = stepHour
-			step.Weather = hourly
-			steps[i] = step
+			steps[i] = s.stepWeather(ctx, step, departure, lang)
 		}()
 	}
 	wg.Wait()
@@ -283,7 +502,107 @@ func (s *Service) weather(ctx context.Context, route *t.Route, delay int64) []t.
 	return weatherSteps
 }
 
-// steps returns the steps from the OSRM route that the service will retrieve forecasted weather data for
+// stepWeather sets the step's ETA from the departure and its forecasted weather at that time, leaving the weather
+// nil if it couldn't be retrieved
+func (s *Service) stepWeather(ctx context.Context, step t.Step, departure int64, lang string) t.Step {
+	step.Arrival = departure + int64(step.TotalDuration)
+	hourly, err := s.hourlyWeather(ctx, step.Coordinates, unixHour(step.Arrival), lang)
+	if err != nil {
+		s.Logger.Warnf("Error getting hourly weather data: %v", err.Error())
+		return step
+	}
+	// providers such as met.no and the redis cache don't return the time zone, which the geocoder may know instead
+	if hourly.Timezone == "" {
+		hourly.Timezone = s.timeZoneName(step.Coordinates)
+	}
+	step.Weather = hourly
+	return step
+}
+
+// hourlyWeather returns the forecasted weather at the coordinates for the hour, preferring data cached in redis.
+// Within a batch, the hour is taken from the point's forecast, which is shared between the batch's journeys
+func (s *Service) hourlyWeather(ctx context.Context, coords t.Coordinates, hour int64, lang string) (*t.Weather, error) {
+	if cached := s.redisHourlyWeather(ctx, coords, hour, lang); cached != nil {
+		return cached, nil
+	}
+	if s.weatherMemo == nil {
+		hourly, err := s.wp.GetHourlyWeather(ctx, coords, hour, lang)
+		if err != nil {
+			return nil, err
+		}
+		hourly.Time = hour
+		return hourly, nil
+	}
+
+	forecast, err := s.forecast(ctx, coords, lang)
+	if err != nil {
+		return nil, err
+	}
+	for i := range forecast {
+		if forecast[i].Time == hour {
+			// copying the forecast as each step owns its weather
+			hourly := forecast[i]
+			return &hourly, nil
+		}
+	}
+	return nil, weather.ErrOutOfRange
+}
+
+// forecast returns the hourly forecast at the coordinates, sharing forecasts within a batch. The forecast must not be
+// modified, as it may be shared
+func (s *Service) forecast(ctx context.Context, coords t.Coordinates, lang string) ([]t.Weather, error) {
+	if s.weatherMemo == nil {
+		return s.wp.GetWeather(ctx, coords.Latitude, coords.Longitude, lang)
+	}
+	// forecasts are shared within 0.1 degrees, similar to the radius searched in redis
+	key := fmt.Sprintf("%.1f,%.1f|%v", coords.Latitude, coords.Longitude, lang)
+	forecast, err := s.weatherMemo.do(key, func() (interface{}, error) {
+		return s.wp.GetWeather(ctx, coords.Latitude, coords.Longitude, lang)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return forecast.([]t.Weather), nil
+}
+
+// redisHourlyWeather returns the forecasted weather at the coordinates for the hour cached in redis, or nil if there
+// is none
+func (s *Service) redisHourlyWeather(ctx context.Context, coords t.Coordinates, hour int64, lang string) *t.Weather {
+	// querying for forecasted weather data cached by wipercheck-loader, which is only cached in English.
+	// ensembles skip the cache as it only holds a single source's forecast
+	_, ensemble := s.wp.(*weather.Ensemble)
+	if !s.disableRedis && !ensemble && lang == i18n.DefaultLang {
+		geoResponse := s.rc.GeoRadius(ctx, strconv.FormatInt(hour, 10), coords.Longitude, coords.Latitude,
+			&redis.GeoRadiusQuery{
+				Radius:    10,
+				Unit:      "km",
+				WithCoord: true,
+				WithDist:  true,
+				Count:     1,
+				Sort:      "ASC",
+			})
+		locations, err := geoResponse.Result()
+		if err != nil {
+			s.Logger.Errorf("Redis error when fetching GeoRadius for (%v, %v): %v",
+				coords.Latitude, coords.Longitude, err.Error())
+		}
+		if len(locations) > 0 {
+			var redisWeather t.RedisHourlyWeather
+			err := json.Unmarshal([]byte(locations[0].Name), &redisWeather)
+			if err != nil {
+				s.Logger.Errorf("Error unmarshalling redis weather for (%v, %v): %v",
+					coords.Latitude, coords.Longitude, err.Error())
+			} else {
+				redisWeather.Hourly.Time = hour
+				redisWeather.Hourly.Sources = []string{"redis"}
+				return redisWeather.Hourly
+			}
+		}
+	}
+	return nil
+}
+
+// steps returns the steps from the route that the service will retrieve forecasted weather data for
 func (s *Service) steps(route *t.Route) []t.Step {
 	var tripDuration, durationStep float64
 	tripDuration = route.Duration
@@ -302,7 +621,7 @@ func (s *Service) steps(route *t.Route) []t.Step {
 	}
 	routeSteps := route.Steps
 	var weatherSteps []t.Step
-	var currentDuration, goalDuration float64
+	var currentDuration, currentDistance, goalDuration float64
 	goalDuration = durationStep
 	for i, step := range routeSteps {
 		// checking if the correct amount of time as specified above has elapsed before we analyze forecasted weather data
@@ -310,6 +629,8 @@ func (s *Service) steps(route *t.Route) []t.Step {
 			weatherStep := routeSteps[i]
 			weatherStep.TotalDuration = math.Round(currentDuration)
 			weatherStep.StepDuration = math.Round(weatherStep.StepDuration)
+			weatherStep.TotalDistance = math.Round(currentDistance)
+			weatherStep.StepDistance = math.Round(weatherStep.StepDistance)
 			// some steps don't include a name if it's the same as the previous one
 			if weatherStep.Name == "" {
 				weatherStep.Name = lastNamedStep(routeSteps, i)
@@ -318,56 +639,88 @@ func (s *Service) steps(route *t.Route) []t.Step {
 			goalDuration = currentDuration + durationStep
 		}
 		currentDuration += step.StepDuration
+		currentDistance += step.StepDistance
 	}
 	return weatherSteps
 }
 
-// response builds the response object for the /journey endpoint, including reverse geocoding coordinates and generating the summary
-func (s *Service) response(ctx context.Context, steps []t.Step, req *JourneyRequest) (*JourneyResponse, error) {
-	resp := &JourneyResponse{}
-	for _, step := range steps {
-		if step.Weather.Pop >= req.minPop {
-			resp.Steps = append(resp.Steps, step)
-		}
-	}
+// locateSteps reverse geocodes the coordinates of the steps without a location
+func (s *Service) locateSteps(ctx context.Context, steps []t.Step, lang string) {
 	wg := new(sync.WaitGroup)
-	wg.Add(len(resp.Steps))
-	for i, step := range resp.Steps {
+	wg.Add(len(steps))
+	for i, step := range steps {
 		// explicitly declaring values as they would change during execution due to async loop otherwise
 		i, step := i, step
 		go func() {
 			defer wg.Done()
-			location, err := s.psc.ReverseGeoCode(ctx, step.Coordinates)
+			// steps that were streamed already have their location
+			if step.Location != nil {
+				return
+			}
+			location, err := s.gc.ReverseGeoCode(ctx, step.Coordinates, lang)
 			if err != nil {
 				s.Logger.Warnf("Error reverse geocoding (%v,%v): %v",
 					step.Coordinates.Latitude, step.Coordinates.Longitude, err.Error())
 				return
 			}
 			step.Location = location
-			resp.Steps[i] = step
+			steps[i] = step
 		}()
 	}
 	wg.Wait()
+}
+
+// response builds the response object for the /journey endpoint, including reverse geocoding coordinates and generating the summary
+func (s *Service) response(ctx context.Context, route *t.Route, steps []t.Step, req *JourneyRequest) (*JourneyResponse, error) {
+	resp := &JourneyResponse{
+		TripSummary: s.tripSummary(route, steps),
+	}
+	// the briefing describes the whole trip, so every step needs a location in that case
+	var located []t.Step
+	for _, step := range steps {
+		if req.format == "briefing" || req.filter.matches(step.Weather) {
+			located = append(located, step)
+		}
+	}
+	s.locateSteps(ctx, located, req.lang)
+	resp.Steps = req.filter.steps(located)
+
+	// the worst step is almost always returned, so reusing its reverse geocoded location
+	if worst := resp.TripSummary.WorstStep; worst != nil {
+		for _, step := range resp.Steps {
+			if step.Arrival == worst.Arrival {
+				worst.Location = step.Location
+			}
+		}
+	}
 
 	var summary []t.SummaryStep
 	for i, step := range resp.Steps {
 		if len(summary) == 0 || summary[len(summary)-1].Pop != step.Weather.Pop*100 || i == len(resp.Steps)-1 {
-			weatherDesc := step.Weather.Conditions.Description
 			summaryStep := t.SummaryStep{
-				Location:   summaryStepLocation(step.Location),
+				Location:   summaryStepLocation(step.Location, step.Coordinates),
 				Pop:        step.Weather.Pop * 100,
-				Conditions: strings.ToUpper(string(weatherDesc[0])) + weatherDesc[1:],
+				Conditions: i18n.Capitalize(step.Weather.Conditions.Description),
 			}
 			summary = append(summary, summaryStep)
 		}
 	}
 	resp.Summary = summary
 
+	if req.format == "briefing" {
+		resp.Briefing = briefing(located, req.lang)
+		resp.Summary = nil
+	}
+
 	return resp, nil
 }
 
-// summaryStepLocation returns a string representation of a Location struct
-func summaryStepLocation(loc *t.Location) string {
+// summaryStepLocation returns a string representation of a Location struct, falling back to the coordinates
+// when there is no location, e.g. if reverse geocoding failed or found nothing
+func summaryStepLocation(loc *t.Location, coords t.Coordinates) string {
+	if loc == nil || (loc.Locality == "" && loc.Region == "") {
+		return fmt.Sprintf("%.4f,%.4f", coords.Latitude, coords.Longitude)
+	}
 	var builder strings.Builder
 	if loc.Locality != "" {
 		builder.WriteString(loc.Locality + ", ")
@@ -389,15 +742,26 @@ func lastNamedStep(routeSteps []t.Step, i int) string {
 }
 
 func writeError(w http.ResponseWriter, err error) {
-	codeErr, ok := err.(CodeError)
-	if ok {
-		bodyBytes, _ := json.Marshal(JourneyResponse{Error: codeErr.Error()})
-		w.WriteHeader(codeErr.code)
-		io.WriteString(w, string(bodyBytes[:]))
-	} else {
+	code, resp := errorResponse(err)
+	if resp == nil {
 		w.WriteHeader(500)
 		io.WriteString(w, "Internal server error")
+		return
+	}
+	bodyBytes, _ := json.Marshal(resp)
+	w.WriteHeader(code)
+	io.WriteString(w, string(bodyBytes[:]))
+}
+
+// errorResponse returns the status code and response body for the error, with a nil body for unexpected errors
+func errorResponse(err error) (int, *JourneyResponse) {
+	if ambiguousErr, ok := err.(AmbiguousError); ok {
+		return 300, &JourneyResponse{Error: ambiguousErr.Error(), Candidates: ambiguousErr.byParam}
+	}
+	if codeErr, ok := err.(CodeError); ok {
+		return codeErr.code, &JourneyResponse{Error: codeErr.Error()}
 	}
+	return 500, nil
 }
 
 func writeResponse(w http.ResponseWriter, resp *JourneyResponse) {
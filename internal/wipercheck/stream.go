@@ -0,0 +1,191 @@
+package wipercheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// StreamRoute is the first event of a streamed journey, with the route and the steps whose weather will follow
+type StreamRoute struct {
+	Geometry        []t.Coordinates `json:"geometry"`
+	DurationMinutes float64         `json:"durationMinutes"`
+	DistanceKm      float64         `json:"distanceKm"`
+	Steps           []t.Step        `json:"steps"`
+}
+
+// StreamDay starts each day of a trip longer than the daily limit, whose route, steps and summary follow
+type StreamDay struct {
+	Index     int      `json:"index"`
+	Departure int64    `json:"departure"`
+	Arrival   int64    `json:"arrival"`
+	Stop      *t.Place `json:"stop,omitempty"`
+}
+
+// StreamStep is sent as each step's weather and location resolve, with its index in the route event's steps
+type StreamStep struct {
+	Index int    `json:"index"`
+	Step  t.Step `json:"step"`
+}
+
+// streamedStep is a step whose weather has resolved, with its index in the route's steps
+type streamedStep struct {
+	index int
+	step  t.Step
+}
+
+// StreamHandler is the handler for the /journey/stream endpoint, sending the journey as Server-Sent Events:
+// the route first, then each step as its weather and location resolve, and finally the summary.
+// Trips longer than the daily limit are sent as these events for each day in turn
+func (s *Service) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, CodeError{code: 500, msg: "Streaming is not supported"})
+		return
+	}
+	ctx := r.Context()
+	req, err := s.validateRequest(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	trip, err := s.tripCoordinates(ctx, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	route, err := s.tripRoute(ctx, trip, req.routeOptions())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// delay is in minutes while step durations are in seconds
+	departure := time.Now().Unix() + req.delay*60
+	// the days are planned before streaming, so that errors splitting the trip are returned as in /journey
+	var days []itineraryDay
+	if req.dailyLimit > 0 && route.Duration > req.dailyLimit*3600 {
+		days, err = s.itineraryDays(ctx, trip, route, departure, req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(200)
+
+	if days == nil {
+		s.streamJourney(ctx, w, flusher, trip, route, departure, req)
+		return
+	}
+	var summaries []*t.TripSummary
+	for i, day := range days {
+		writeEvent(w, flusher, "day", StreamDay{
+			Index:     i,
+			Departure: day.departure,
+			Arrival:   day.departure + int64(day.route.Duration),
+			Stop:      day.stop,
+		})
+		resp := s.streamJourney(ctx, w, flusher, day.trip, day.route, day.departure, dayRequest(req, i, len(days)))
+		if resp == nil {
+			return
+		}
+		summaries = append(summaries, resp.TripSummary)
+	}
+	writeEvent(w, flusher, "itinerary", JourneyResponse{TripSummary: combineSummaries(summaries...)})
+}
+
+// streamJourney sends the route, steps and summary events of the trip's route departing at the unix time, returning
+// the summary, or nil once an error event has been sent instead
+func (s *Service) streamJourney(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, trip *t.Trip, route *t.Route, departure int64, req *JourneyRequest) *JourneyResponse {
+	routeSteps := s.steps(route)
+	for i := range routeSteps {
+		routeSteps[i].Arrival = departure + int64(routeSteps[i].TotalDuration)
+	}
+	writeEvent(w, flusher, "route", StreamRoute{
+		Geometry:        route.Geometry,
+		DurationMinutes: math.Round(route.Duration / 60),
+		DistanceKm:      math.Round(route.Distance/100) / 10,
+		Steps:           routeSteps,
+	})
+
+	var resolved []streamedStep
+	for step := range s.streamSteps(ctx, routeSteps, departure, req) {
+		resolved = append(resolved, step)
+		if req.filter.matches(step.step.Weather) {
+			writeEvent(w, flusher, "step", StreamStep{Index: step.index, Step: step.step})
+		}
+	}
+
+	sort.Slice(resolved, func(i, j int) bool {
+		return resolved[i].index < resolved[j].index
+	})
+	steps := make([]t.Step, len(resolved))
+	for i, step := range resolved {
+		steps[i] = step.step
+	}
+	resp, err := s.journeyResponse(ctx, trip, route, steps, departure, req)
+	if err != nil {
+		_, errResp := errorResponse(err)
+		if errResp == nil {
+			errResp = &JourneyResponse{Error: "Internal server error"}
+		}
+		writeEvent(w, flusher, "error", errResp)
+		return nil
+	}
+	// the steps were already sent as they resolved
+	resp.Steps = nil
+	writeEvent(w, flusher, "summary", resp)
+	return resp
+}
+
+// streamSteps forecasts the steps concurrently, sending each step with weather once its hazards and
+// location have resolved. Steps without weather are dropped, and the channel is closed once all have resolved
+func (s *Service) streamSteps(ctx context.Context, steps []t.Step, departure int64, req *JourneyRequest) <-chan streamedStep {
+	resolved := make(chan streamedStep)
+	done := make(chan struct{})
+	for i, step := range steps {
+		i, step := i, step
+		go func() {
+			defer func() { done <- struct{}{} }()
+			step = s.stepWeather(ctx, step, departure, req.lang)
+			if step.Weather == nil {
+				return
+			}
+			single := []t.Step{step}
+			markHazards(single, req.vehicle, req.lang)
+			step = single[0]
+			// only the steps that will be returned are reverse geocoded, as in the full response
+			if req.filter.matches(step.Weather) {
+				location, err := s.gc.ReverseGeoCode(ctx, step.Coordinates, req.lang)
+				if err != nil {
+					s.Logger.Warnf("Error reverse geocoding (%v,%v): %v",
+						step.Coordinates.Latitude, step.Coordinates.Longitude, err.Error())
+				}
+				step.Location = location
+			}
+			resolved <- streamedStep{index: i, step: step}
+		}()
+	}
+	go func() {
+		for range steps {
+			<-done
+		}
+		close(resolved)
+	}()
+	return resolved
+}
+
+// writeEvent writes the value as a Server-Sent Event and flushes it to the client
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, event string, v interface{}) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "event: %v\ndata: %s\n\n", event, data)
+	flusher.Flush()
+}
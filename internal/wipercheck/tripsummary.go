@@ -0,0 +1,72 @@
+package wipercheck
+
+import (
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"math"
+)
+
+// defaultExposureLevels are the precipitation chances (in percent) exposure is reported for if not configured
+var defaultExposureLevels = []float64{30, 50, 70}
+
+// tripSummary calculates the overall precipitation exposure metrics for the trip from the weather steps
+func (s *Service) tripSummary(route *t.Route, steps []t.Step) *t.TripSummary {
+	summary := &t.TripSummary{
+		DurationMinutes: math.Round(route.Duration / 60),
+		DistanceKm:      math.Round(route.Distance/100) / 10,
+	}
+	exposure := make([]t.Exposure, len(s.exposureLevels))
+	for i, level := range s.exposureLevels {
+		exposure[i].MinPop = level
+	}
+
+	var weighted, worstScore float64
+	var prevDuration, prevDistance float64
+	for i, step := range steps {
+		// each step covers the trip since the previous step, with the last step also covering the rest of the trip
+		endDuration, endDistance := step.TotalDuration, step.TotalDistance
+		if i == len(steps)-1 {
+			endDuration, endDistance = route.Duration, route.Distance
+		}
+		duration, distance := endDuration-prevDuration, endDistance-prevDistance
+		prevDuration, prevDistance = endDuration, endDistance
+
+		for j := range exposure {
+			if step.Weather.Pop*100 >= exposure[j].MinPop {
+				exposure[j].Minutes += duration / 60
+				exposure[j].Km += distance / 1000
+			}
+		}
+
+		if len(step.Hazards) > 0 {
+			summary.HazardMinutes += duration / 60
+		}
+
+		score := step.Weather.Pop * severity(precipKind(step.Weather.Conditions))
+		weighted += score * duration
+		if score > worstScore {
+			worstScore = score
+			worst := step
+			summary.WorstStep = &worst
+		}
+
+		if isWet(step.Weather) {
+			if summary.FirstWetETA == 0 {
+				summary.FirstWetETA = step.Arrival
+			}
+			summary.LastWetETA = step.Arrival
+		}
+	}
+
+	for j := range exposure {
+		exposure[j].Minutes = math.Round(exposure[j].Minutes)
+		exposure[j].Km = math.Round(exposure[j].Km*10) / 10
+	}
+	summary.Exposure = exposure
+	summary.HazardMinutes = math.Round(summary.HazardMinutes)
+
+	// the wiper index is the duration-weighted precipitation chance scaled by severity, capped at 100
+	if route.Duration > 0 {
+		summary.WiperIndex = math.Round(math.Min(100, weighted/route.Duration*100)*10) / 10
+	}
+	return summary
+}
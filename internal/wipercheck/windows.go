@@ -0,0 +1,80 @@
+package wipercheck
+
+import (
+	"context"
+	"fmt"
+	t "github.com/evanhutnik/wipercheck-service/internal/types"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxWindowHours is the largest origin or destination window that can be requested
+const maxWindowHours = 12
+
+// windowParam parses an optional weather window parameter, in hours
+func windowParam(r *http.Request, param string) (int64, error) {
+	if r.URL.Query().Get(param) == "" {
+		return 0, nil
+	}
+	hours, err := strconv.ParseInt(r.URL.Query().Get(param), 10, 64)
+	if err != nil || hours < 1 || hours > maxWindowHours {
+		return 0, CodeError{code: 400, msg: fmt.Sprintf("'%v' parameter must be between 1 and %v hours", param, maxWindowHours)}
+	}
+	return hours, nil
+}
+
+// weatherWindows adds the requested hourly forecasts at the origin before departure and at the destination after arrival
+func (s *Service) weatherWindows(ctx context.Context, resp *JourneyResponse, trip *t.Trip, route *t.Route, departure int64, req *JourneyRequest) {
+	wg := new(sync.WaitGroup)
+	if req.originWindow > 0 {
+		// the origin window leads up to the departure hour, without going back further than the current hour
+		start := unixHour(departure) - (req.originWindow-1)*3600
+		if now := unixHour(time.Now().Unix()); start < now {
+			start = now
+		}
+		hours := (unixHour(departure)-start)/3600 + 1
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp.OriginWindow = s.forecastWindow(ctx, *trip.From, start, hours, req.lang)
+		}()
+	}
+	if req.destinationWindow > 0 {
+		arrival := departure + int64(route.Duration)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp.DestinationWindow = s.forecastWindow(ctx, *trip.To, unixHour(arrival), req.destinationWindow, req.lang)
+		}()
+	}
+	wg.Wait()
+}
+
+// forecastWindow returns the hourly forecast at the coordinates for the given number of hours from the start hour,
+// taken from a single forecast of the point
+func (s *Service) forecastWindow(ctx context.Context, coords t.Coordinates, start int64, hours int64, lang string) *t.ForecastWindow {
+	window := &t.ForecastWindow{Coordinates: coords}
+	forecast, err := s.forecast(ctx, coords, lang)
+	if err != nil {
+		s.Logger.Warnf("Error getting weather data for window at (%v,%v): %v",
+			coords.Latitude, coords.Longitude, err.Error())
+		return window
+	}
+
+	// only including the window's hours that are in the forecast
+	end := start + hours*3600
+	for i := range forecast {
+		if hour := forecast[i].Time; hour >= start && hour < end {
+			hourly := forecast[i]
+			window.Hours = append(window.Hours, t.HourlyForecast{Time: hour, Weather: &hourly})
+		}
+	}
+	return window
+}
+
+// unixHour truncates a unix timestamp to the start of its hour
+func unixHour(unixTime int64) int64 {
+	return time.Unix(unixTime, 0).UTC().Truncate(time.Hour).Unix()
+}